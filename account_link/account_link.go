@@ -0,0 +1,100 @@
+package account_link
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"speechLiason/cloud_resources"
+	"speechLiason/errors"
+	"strings"
+	"time"
+)
+
+var timeout = time.Duration(5 * time.Second)
+var mappings MappingStore = DynamoMappingStore{}
+var introspector TokenIntrospector = HttpIntrospector{
+	Url:          os.Getenv("TOKEN_INTROSPECTION_URL"),
+	ClientId:     os.Getenv("TOKEN_INTROSPECTION_CLIENT_ID"),
+	ClientSecret: os.Getenv("TOKEN_INTROSPECTION_CLIENT_SECRET"),
+}
+
+type TokenIntrospector interface {
+	Introspect(accessToken string) (syncUserId string, err error)
+}
+
+// HttpIntrospector resolves tokens against an RFC 7662 introspection endpoint
+type HttpIntrospector struct {
+	Url          string
+	ClientId     string
+	ClientSecret string
+}
+
+type introspectionResponse struct {
+	Active  bool   `json:"active"`
+	Subject string `json:"sub"`
+}
+
+func (h HttpIntrospector) Introspect(accessToken string) (string, error) {
+	form := url.Values{"token": {accessToken}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequest(http.MethodPost, h.Url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.SystemError{Context: "Introspect", Log: fmt.Sprintf("could not create a request object to introspect token: %s", err)}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(h.ClientId, h.ClientSecret)
+	c := http.Client{
+		Timeout: timeout,
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", errors.SystemError{Context: "Introspect", Log: fmt.Sprintf("could not introspect token: %s", err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 399 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return "", errors.SystemError{Context: "Introspect", Log: fmt.Sprintf("could not introspect token: %d, %s", resp.StatusCode, b)}
+	}
+	var data introspectionResponse
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", errors.SystemError{Context: "Introspect", Log: fmt.Sprintf("could not decode introspection response: %s", err)}
+	}
+	if !data.Active || data.Subject == "" {
+		return "", errors.UserAccountNotSyncedError{Context: "Introspect", Log: "access token is inactive or has no subject"}
+	}
+	return data.Subject, nil
+}
+
+// StaticIntrospector answers from a fixed token to sync user table
+type StaticIntrospector map[string]string
+
+func (s StaticIntrospector) Introspect(accessToken string) (string, error) {
+	syncUserId, ok := s[accessToken]
+	if !ok {
+		return "", errors.UserAccountNotSyncedError{Context: "Introspect", Log: "unknown access token"}
+	}
+	return syncUserId, nil
+}
+
+func SetIntrospector(i TokenIntrospector) {
+	introspector = i
+}
+
+// LinkFromAccessToken maps a voice user to the sync user behind their linked-account token. A voice
+// user who is already mapped is left alone, so the token is only introspected the first time.
+func LinkFromAccessToken(voiceUserId, accessToken string) (syncUserId string, err error) {
+	um, err := mappings.GetUserMapping(voiceUserId)
+	if err != nil {
+		return "", err
+	}
+	if um != nil {
+		return um.SyncUserId, nil
+	}
+	syncUserId, err = introspector.Introspect(accessToken)
+	if err != nil {
+		return "", err
+	}
+	return syncUserId, mappings.SaveUserMapping(voiceUserId, cloud_resources.DefaultAccountName, syncUserId)
+}
@@ -0,0 +1,71 @@
+package account_link
+
+import (
+	"speechLiason/cloud_resources"
+	"speechLiason/errors"
+	"testing"
+)
+
+type countingIntrospector struct {
+	StaticIntrospector
+	calls int
+}
+
+func (c *countingIntrospector) Introspect(accessToken string) (string, error) {
+	c.calls++
+	return c.StaticIntrospector.Introspect(accessToken)
+}
+
+func TestLinkFromAccessTokenSavesNewMapping(t *testing.T) {
+	store := MemoryMappingStore{}
+	SetMappingStore(store)
+	SetIntrospector(StaticIntrospector{"token": "sync-1"})
+
+	syncUserId, err := LinkFromAccessToken("voice-1", "token")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if syncUserId != "sync-1" {
+		t.Errorf("got sync user %q, want sync-1", syncUserId)
+	}
+	um := store["voice-1"]
+	if um == nil || um.SyncUserId != "sync-1" || um.Accounts[cloud_resources.DefaultAccountName] != "sync-1" {
+		t.Errorf("mapping not saved under the default account: %+v", um)
+	}
+}
+
+func TestLinkFromAccessTokenSkipsMappedUsers(t *testing.T) {
+	store := MemoryMappingStore{}
+	_ = store.SaveUserMapping("voice-1", "work", "sync-work")
+	SetMappingStore(store)
+	i := &countingIntrospector{StaticIntrospector: StaticIntrospector{"token": "sync-1"}}
+	SetIntrospector(i)
+
+	syncUserId, err := LinkFromAccessToken("voice-1", "token")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if syncUserId != "sync-work" {
+		t.Errorf("got sync user %q, want the active sync-work", syncUserId)
+	}
+	if i.calls != 0 {
+		t.Errorf("introspected %d times for an already mapped user", i.calls)
+	}
+	if len(store["voice-1"].Accounts) != 1 {
+		t.Errorf("mapping changed: %+v", store["voice-1"])
+	}
+}
+
+func TestLinkFromAccessTokenRejectsUnknownToken(t *testing.T) {
+	store := MemoryMappingStore{}
+	SetMappingStore(store)
+	SetIntrospector(StaticIntrospector{})
+
+	_, err := LinkFromAccessToken("voice-1", "expired")
+	if _, ok := err.(errors.UserAccountNotSyncedError); !ok {
+		t.Fatalf("got %v, want UserAccountNotSyncedError", err)
+	}
+	if _, ok := store["voice-1"]; ok {
+		t.Error("saved a mapping for an unknown token")
+	}
+}
@@ -0,0 +1,42 @@
+package account_link
+
+import (
+	"speechLiason/cloud_resources"
+)
+
+type MappingStore interface {
+	GetUserMapping(voiceUserId string) (*cloud_resources.UserMapping, error)
+	SaveUserMapping(voiceUserId, accountName, syncUserId string) error
+}
+
+type DynamoMappingStore struct{}
+
+func (DynamoMappingStore) GetUserMapping(voiceUserId string) (*cloud_resources.UserMapping, error) {
+	return cloud_resources.GetUserMapping(voiceUserId)
+}
+
+func (DynamoMappingStore) SaveUserMapping(voiceUserId, accountName, syncUserId string) error {
+	return cloud_resources.SaveUserMapping(voiceUserId, accountName, syncUserId)
+}
+
+// MemoryMappingStore keeps mappings by voice user instead of in DynamoDB
+type MemoryMappingStore map[string]*cloud_resources.UserMapping
+
+func (m MemoryMappingStore) GetUserMapping(voiceUserId string) (*cloud_resources.UserMapping, error) {
+	return m[voiceUserId], nil
+}
+
+func (m MemoryMappingStore) SaveUserMapping(voiceUserId, accountName, syncUserId string) error {
+	um, ok := m[voiceUserId]
+	if !ok {
+		um = &cloud_resources.UserMapping{VoiceUserId: voiceUserId, Accounts: map[string]string{}}
+		m[voiceUserId] = um
+	}
+	um.Accounts[accountName] = syncUserId
+	um.ActiveAccount, um.SyncUserId = accountName, syncUserId
+	return nil
+}
+
+func SetMappingStore(s MappingStore) {
+	mappings = s
+}
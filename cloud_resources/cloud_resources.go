@@ -10,15 +10,20 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
 	"speechLiason/errors"
 	"time"
+	_ "time/tzdata"
 )
 
 var userEmailUrl = "https://api.amazonalexa.com/v2/accounts/~current/settings/Profile.email"
 var deviceUrlSegments = [2]string{"https://api.amazonalexa.com/v1/devices/", "/settings/address"}
+var timeZoneUrlSegments = [2]string{"https://api.amazonalexa.com/v2/devices/", "/settings/System.timeZone"}
 var timeout = time.Duration(5 * time.Second)
 var db = dynamodb.New(session.New(), aws.NewConfig().WithRegion("us-east-1"))
 
+const DefaultAccountName = "main"
+
 type DeviceAddress struct {
 	StateOrRegion     string `json:"stateOrRegion"`
 	City              string `json:"city"`
@@ -32,8 +37,31 @@ type DeviceAddress struct {
 }
 
 type UserMapping struct {
-	VoiceUserId string `json:"voiceUserId"`
-	SyncUserId  string `json:"syncUserId"`
+	VoiceUserId   string            `json:"voiceUserId"`
+	SyncUserId    string            `json:"syncUserId"`
+	ActiveAccount string            `json:"activeAccount"`
+	Accounts      map[string]string `json:"accounts"`
+}
+
+// UserPreferences keeps a delivery format apart from the zip and protect flags, so they can be combined
+type UserPreferences struct {
+	SyncUserId        string `json:"syncUserId"`
+	CursorTtlMinutes  int    `json:"cursorTtlMinutes,omitempty"`
+	SyncTtlMinutes    int    `json:"syncTtlMinutes,omitempty"`
+	DeliveryFormat    string `json:"deliveryFormat,omitempty"`
+	ZipDeliveries     bool   `json:"zipDeliveries,omitempty"`
+	ProtectDeliveries bool   `json:"protectDeliveries,omitempty"`
+	PdfPasswordRef    string `json:"pdfPasswordRef,omitempty"`
+	MaxAttachmentMB   int    `json:"maxAttachmentMB,omitempty"`
+}
+
+func (um *UserMapping) AccountNames() []string {
+	names := make([]string, 0, len(um.Accounts))
+	for n := range um.Accounts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func GetDeviceAddress(token, deviceId string) (DeviceAddress, error) {
@@ -61,6 +89,36 @@ func GetDeviceAddress(token, deviceId string) (DeviceAddress, error) {
 	return data, err
 }
 
+func GetDeviceTimeZone(token, deviceId string) (*time.Location, error) {
+	req, err := http.NewRequest(http.MethodGet, timeZoneUrlSegments[0]+deviceId+timeZoneUrlSegments[1], nil)
+	if err != nil {
+		return nil, errors.SystemError{JobName: "", UserId: "", Context: "GetDeviceTimeZone", Log: fmt.Sprintf("could not create a request object to get device time zone: %s", err)}
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	c := http.Client{
+		Timeout: timeout,
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, errors.SystemError{JobName: "", UserId: "", Context: "GetDeviceTimeZone", Log: fmt.Sprintf("could not get device time zone: %s", err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 399 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.SystemError{JobName: "", UserId: "", Context: "GetDeviceTimeZone", Log: fmt.Sprintf("could not get device time zone: %d, %s", resp.StatusCode, b)}
+	}
+	// the zone comes back as a JSON string, like "America/Los_Angeles"
+	var name string
+	if err = json.NewDecoder(resp.Body).Decode(&name); err != nil {
+		return nil, errors.SystemError{JobName: "", UserId: "", Context: "GetDeviceTimeZone", Log: fmt.Sprintf("could not read device time zone: %s", err)}
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.SystemError{JobName: "", UserId: "", Context: "GetDeviceTimeZone", Log: fmt.Sprintf("unknown device time zone %s: %s", name, err)}
+	}
+	return loc, nil
+}
+
 func GetUserEmail(token, voiceUserId string) (userEmail string, err error) {
 	req, err := http.NewRequest(http.MethodGet, userEmailUrl, nil)
 	if err != nil {
@@ -105,24 +163,160 @@ func GetUserMapping(voiceUserId string) (userMapping *UserMapping, err error) {
 	if err != nil {
 		return &UserMapping{}, errors.SystemError{JobName: "", UserId: voiceUserId, Context: "GetUserMapping", Log: fmt.Sprintf("could not unmarshal dynamodb attributes: %s", err)}
 	}
+	// mappings saved before multiple accounts were supported only hold the one sync user
+	if len(userMapping.Accounts) == 0 && userMapping.SyncUserId != "" {
+		userMapping.Accounts = map[string]string{DefaultAccountName: userMapping.SyncUserId}
+		userMapping.ActiveAccount = DefaultAccountName
+	}
+	if userMapping.Accounts == nil {
+		userMapping.Accounts = map[string]string{}
+	}
 	return
 }
 
-func SaveUserMapping(voiceUserId, syncUserId string) error {
-	input := &dynamodb.PutItemInput{
+func SaveUserMapping(voiceUserId, accountName, syncUserId string) error {
+	um, err := GetUserMapping(voiceUserId)
+	if err != nil {
+		return err
+	}
+	previousSyncUserId := ""
+	if um == nil {
+		um = &UserMapping{VoiceUserId: voiceUserId, Accounts: map[string]string{}}
+	} else {
+		previousSyncUserId = um.SyncUserId
+	}
+	um.Accounts[accountName] = syncUserId
+	um.ActiveAccount = accountName
+	um.SyncUserId = syncUserId
+	return putUserMapping(um, previousSyncUserId, "SaveUserMapping")
+}
+
+func ReplaceUserMapping(voiceUserId, previousSyncUserId, syncUserId string) error {
+	um, err := GetUserMapping(voiceUserId)
+	if err != nil {
+		return err
+	}
+	if um == nil {
+		um = &UserMapping{VoiceUserId: voiceUserId, ActiveAccount: DefaultAccountName, Accounts: map[string]string{}}
+	}
+	// replaces whichever account is active, keeping its name
+	um.Accounts[um.ActiveAccount] = syncUserId
+	um.SyncUserId = syncUserId
+	return putUserMapping(um, previousSyncUserId, "ReplaceUserMapping")
+}
+
+func SwitchUserMapping(voiceUserId, accountName string) (*UserMapping, error) {
+	um, err := GetUserMapping(voiceUserId)
+	if err != nil {
+		return nil, err
+	}
+	if um == nil {
+		return nil, errors.UserAccountNotSyncedError{UserId: voiceUserId, Context: "SwitchUserMapping", Log: fmt.Sprintf("voice user %s has no linked accounts", voiceUserId)}
+	}
+	syncUserId, ok := um.Accounts[accountName]
+	if !ok {
+		return nil, errors.LinkedAccountNotFoundError{ContextualError: errors.ContextualError{UserId: voiceUserId, Context: "SwitchUserMapping", Log: fmt.Sprintf("no linked account named %s", accountName)}, AccountName: accountName, Available: um.AccountNames()}
+	}
+	previousSyncUserId := um.SyncUserId
+	um.ActiveAccount = accountName
+	um.SyncUserId = syncUserId
+	return um, putUserMapping(um, previousSyncUserId, "SwitchUserMapping")
+}
+
+func RemoveLinkedAccount(voiceUserId, accountName string) (remaining *UserMapping, err error) {
+	um, err := GetUserMapping(voiceUserId)
+	if err != nil || um == nil {
+		return nil, err
+	}
+	delete(um.Accounts, accountName)
+	if len(um.Accounts) == 0 {
+		return nil, DeleteUserMapping(voiceUserId)
+	}
+	previousSyncUserId := um.SyncUserId
+	if um.ActiveAccount == accountName {
+		um.ActiveAccount = um.AccountNames()[0]
+		um.SyncUserId = um.Accounts[um.ActiveAccount]
+	}
+	return um, putUserMapping(um, previousSyncUserId, "RemoveLinkedAccount")
+}
+
+func DeleteUserMapping(voiceUserId string) error {
+	input := &dynamodb.DeleteItemInput{
 		TableName: aws.String(os.Getenv("USER_MAPPINGS_TABLE")),
-		Item: map[string]*dynamodb.AttributeValue{
+		Key: map[string]*dynamodb.AttributeValue{
 			"voiceUserId": {
 				S: aws.String(voiceUserId),
 			},
+		},
+	}
+	_, err := db.DeleteItem(input)
+	if err != nil {
+		return errors.SystemError{UserId: voiceUserId, Context: "DeleteUserMapping", Log: fmt.Sprintf("error while deleting user mapping: %s", err)}
+	}
+	return nil
+}
+
+func GetUserPreferences(syncUserId string) (UserPreferences, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("USER_PREFERENCES_TABLE")),
+		Key: map[string]*dynamodb.AttributeValue{
 			"syncUserId": {
 				S: aws.String(syncUserId),
 			},
 		},
 	}
-	_, err := db.PutItem(input)
+	result, err := db.GetItem(input)
+	if err != nil {
+		return UserPreferences{}, errors.SystemError{UserId: syncUserId, Context: "GetUserPreferences", Log: fmt.Sprintf("could not get user preferences: %s", err)}
+	}
+	prefs := UserPreferences{SyncUserId: syncUserId}
+	if result.Item == nil {
+		return prefs, nil
+	}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &prefs); err != nil {
+		return UserPreferences{}, errors.SystemError{UserId: syncUserId, Context: "GetUserPreferences", Log: fmt.Sprintf("could not unmarshal dynamodb attributes: %s", err)}
+	}
+	return prefs, nil
+}
+
+func SaveUserPreferences(prefs UserPreferences) error {
+	item, err := dynamodbattribute.MarshalMap(prefs)
+	if err != nil {
+		return errors.SystemError{UserId: prefs.SyncUserId, Context: "SaveUserPreferences", Log: fmt.Sprintf("could not marshal user preferences: %s", err)}
+	}
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("USER_PREFERENCES_TABLE")),
+		Item:      item,
+	}
+	if _, err = db.PutItem(input); err != nil {
+		return errors.SystemError{UserId: prefs.SyncUserId, Context: "SaveUserPreferences", Log: fmt.Sprintf("error while persisting user preferences to database: %s", err)}
+	}
+	return nil
+}
+
+func putUserMapping(um *UserMapping, previousSyncUserId string, context string) error {
+	item, err := dynamodbattribute.MarshalMap(um)
+	if err != nil {
+		return errors.SystemError{UserId: um.VoiceUserId, Context: context, Log: fmt.Sprintf("could not marshal user mapping: %s", err)}
+	}
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("USER_MAPPINGS_TABLE")),
+		Item:      item,
+	}
+	// only replace the mapping we read, so a concurrent sync isn't silently overwritten
+	if previousSyncUserId == "" {
+		input.ConditionExpression = aws.String("attribute_not_exists(voiceUserId)")
+	} else {
+		input.ConditionExpression = aws.String("syncUserId = :previous")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":previous": {
+				S: aws.String(previousSyncUserId),
+			},
+		}
+	}
+	_, err = db.PutItem(input)
 	if err != nil {
-		return errors.SystemError{UserId: voiceUserId, Context: "SaveUserMapping", Log: fmt.Sprintf("error while persisting user mapping to database: %s", err)}
+		return errors.SystemError{UserId: um.VoiceUserId, Context: context, Log: fmt.Sprintf("error while persisting user mapping to database: %s", err)}
 	}
 	return nil
 }
@@ -0,0 +1,165 @@
+package delivery
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"google.golang.org/api/iterator"
+	"speechLiason/errors"
+	"speechLiason/job_names"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const contactCollection = "contact"
+
+type Contact struct {
+	UserId        string `firestore:"u"`
+	Name          string `firestore:"n"`
+	Email         string `firestore:"e"`
+	Phone         string `firestore:"f"`
+	DefaultMethod string `firestore:"m"`
+}
+
+// Address is where the contact receives deliveries by method, or empty if they can't
+func (c Contact) Address(method string) string {
+	switch method {
+	case "email":
+		return c.Email
+	case "fax":
+		return c.Phone
+	}
+	return ""
+}
+
+// destinationAddress is Address in the form the method delivers to; email destinations carry
+// the quotes the profile API returns addresses with, so contacts' addresses get them too
+func (c Contact) destinationAddress(method string) string {
+	a := c.Address(method)
+	if method == "email" && a != "" {
+		return strconv.Quote(a)
+	}
+	return a
+}
+
+func SaveContact(r Requester, c Contact) error {
+	c.UserId = r.SyncUserId
+	c.Name = job_names.Canonicalize(c.Name)
+	if c.Name == "" {
+		return errors.InvalidInputError{ContextualError: errors.ContextualError{UserId: r.SyncUserId, Context: "SaveContact", Log: "contact has no name"}, ErroneousInput: "contact name"}
+	}
+	if c.DefaultMethod == "" {
+		c.DefaultMethod = "email"
+		if c.Email == "" {
+			c.DefaultMethod = "fax"
+		}
+	}
+	if c.Address(c.DefaultMethod) == "" {
+		return errors.InvalidInputError{ContextualError: errors.ContextualError{UserId: r.SyncUserId, Context: "SaveContact", Log: fmt.Sprintf("contact %s has no address for %s", c.Name, c.DefaultMethod)}, ErroneousInput: c.DefaultMethod + " address"}
+	}
+	for _, method := range []string{"email", "fax"} {
+		if a := c.destinationAddress(method); a != "" {
+			if err := methods[method].ValidateDestination(a); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := r.Client.Collection(contactCollection).Doc(contactKey(c.UserId, c.Name)).Set(r.Ctx, c); err != nil {
+		return errors.SystemError{UserId: r.SyncUserId, Context: "SaveContact", Log: fmt.Sprintf("could not save contact %s: %s", c.Name, err)}
+	}
+	return nil
+}
+
+func RemoveContact(r Requester, name string) error {
+	c, err := FindContact(r, name)
+	if err != nil {
+		return err
+	}
+	if _, err = r.Client.Collection(contactCollection).Doc(contactKey(c.UserId, c.Name)).Delete(r.Ctx); err != nil {
+		return errors.SystemError{UserId: r.SyncUserId, Context: "RemoveContact", Log: fmt.Sprintf("could not remove contact %s: %s", c.Name, err)}
+	}
+	return nil
+}
+
+func ListContacts(r Requester) ([]Contact, error) {
+	iter := r.Client.Collection(contactCollection).Where("u", "==", r.SyncUserId).OrderBy("n", firestore.Asc).Documents(r.Ctx)
+	defer iter.Stop()
+	var cs []Contact
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.SystemError{UserId: r.SyncUserId, Context: "ListContacts", Log: fmt.Sprintf("could not list contacts: %s", err)}
+		}
+		var c Contact
+		if err = doc.DataTo(&c); err != nil {
+			return nil, errors.SystemError{UserId: r.SyncUserId, Context: "ListContacts", Log: fmt.Sprintf("could not read contact: %s", err)}
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+func FindContact(r Requester, name string) (Contact, error) {
+	snap, err := r.Client.Collection(contactCollection).Doc(contactKey(r.SyncUserId, job_names.Canonicalize(name))).Get(r.Ctx)
+	if err == nil && snap.Exists() {
+		var c Contact
+		if err = snap.DataTo(&c); err != nil {
+			return Contact{}, errors.SystemError{UserId: r.SyncUserId, Context: "FindContact", Log: fmt.Sprintf("could not read contact %s: %s", name, err)}
+		}
+		return c, nil
+	}
+	cs, err := ListContacts(r)
+	if err != nil {
+		return Contact{}, err
+	}
+	names := make([]string, len(cs))
+	for i, c := range cs {
+		names[i] = c.Name
+	}
+	return Contact{}, errors.ContactNotFoundError{ContextualError: errors.ContextualError{UserId: r.SyncUserId, Context: "FindContact", Log: fmt.Sprintf("no contact called %s", name)}, Contact: name, Available: names}
+}
+
+// contactDestination resolves a spoken contact to where they receive deliveries by method
+func contactDestination(r Requester, method string) (Destination, error) {
+	c, err := FindContact(r, r.Target)
+	if err != nil {
+		return Destination{}, err
+	}
+	a := c.destinationAddress(method)
+	if a == "" {
+		return Destination{}, errors.ContactNotFoundError{ContextualError: errors.ContextualError{UserId: r.SyncUserId, Context: "contactDestination", Log: fmt.Sprintf("contact %s has no %s address", c.Name, method)}, Contact: c.Name, MissingMethod: method}
+	}
+	return Destination{UserId: r.SyncUserId, Method: method, Name: c.Name, Address: a}, nil
+}
+
+// SpokenEmail turns "dana at example dot com" into dana@example.com
+func SpokenEmail(spoken string) string {
+	s := " " + strings.ToLower(spoken) + " "
+	s = strings.Replace(s, " at ", "@", -1)
+	s = strings.Replace(s, " dot ", ".", -1)
+	return strings.Join(strings.Fields(s), "")
+}
+
+// SpokenPhone keeps the digits of a number, assuming a North American number when there's no country code
+func SpokenPhone(spoken string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, spoken)
+	if digits == "" {
+		return ""
+	}
+	if !strings.HasPrefix(strings.TrimSpace(spoken), "+") && len(digits) == 10 {
+		digits = "1" + digits
+	}
+	return "+" + digits
+}
+
+func contactKey(syncUserId, name string) string {
+	return syncUserId + "_" + name
+}
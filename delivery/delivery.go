@@ -0,0 +1,56 @@
+package delivery
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"sort"
+	"speechLiason/errors"
+)
+
+var methods = map[string]Method{}
+
+// Requester carries what a method may need to work out where a user's job should go
+type Requester struct {
+	VoiceUserId string
+	SyncUserId  string
+	ApiToken    string
+	// Target is the spoken name of a destination, like a folder or printer; empty means the user's default
+	Target string
+	Client *firestore.Client
+	Ctx    context.Context
+}
+
+type Method interface {
+	Name() string
+	ValidateDestination(address string) error
+	ResolveDestination(r Requester) (Destination, error)
+	// Payload holds the method-specific fields the delivery worker needs, stored on the delivery doc
+	Payload(d Destination) map[string]interface{}
+}
+
+// DestinationPreparer is implemented by methods that check or fill in fields of their own when a destination is saved
+type DestinationPreparer interface {
+	PrepareDestination(d Destination) (Destination, error)
+}
+
+func Register(m Method) {
+	methods[m.Name()] = m
+}
+
+func Lookup(name string) (Method, error) {
+	m, ok := methods[name]
+	if !ok {
+		return nil, errors.UnsupportedOperationError{ContextualError: errors.ContextualError{Context: "Lookup", Log: fmt.Sprintf("method for delivery %s not yet supported", name)}, ErroneousOperation: name, Available: Available()}
+	}
+	return m, nil
+}
+
+func Available() []string {
+	names := make([]string, 0, len(methods))
+	for n := range methods {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
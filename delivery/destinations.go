@@ -0,0 +1,104 @@
+package delivery
+
+import (
+	"fmt"
+	"google.golang.org/api/iterator"
+	"speechLiason/errors"
+	"speechLiason/job_names"
+)
+
+const destinationCollection = "destination"
+
+// Destination is somewhere a sync user has registered for a method to deliver to, like a webhook or a printer
+type Destination struct {
+	UserId  string            `firestore:"u"`
+	Method  string            `firestore:"m"`
+	Name    string            `firestore:"n"`
+	Address string            `firestore:"a"`
+	Fields  map[string]string `firestore:"f"`
+}
+
+func SaveDestination(r Requester, d Destination) error {
+	m, err := Lookup(d.Method)
+	if err != nil {
+		return err
+	}
+	if err = m.ValidateDestination(d.Address); err != nil {
+		return err
+	}
+	d.UserId = r.SyncUserId
+	d.Name = job_names.Canonicalize(d.Name)
+	if d.Name == "" {
+		return errors.InvalidInputError{ContextualError: errors.ContextualError{UserId: r.SyncUserId, Context: "SaveDestination", Log: fmt.Sprintf("%s destination has no name", d.Method)}, ErroneousInput: d.Method + " name"}
+	}
+	if p, ok := m.(DestinationPreparer); ok {
+		if d, err = p.PrepareDestination(d); err != nil {
+			return err
+		}
+	}
+	if _, err = r.Client.Collection(destinationCollection).Doc(destinationKey(d.UserId, d.Method, d.Name)).Set(r.Ctx, d); err != nil {
+		return errors.SystemError{UserId: r.SyncUserId, Context: "SaveDestination", Log: fmt.Sprintf("could not save %s destination %s: %s", d.Method, d.Name, err)}
+	}
+	return nil
+}
+
+func RemoveDestination(r Requester, method, name string) error {
+	d, err := findDestination(r, method, name)
+	if err != nil {
+		return err
+	}
+	if _, err = r.Client.Collection(destinationCollection).Doc(destinationKey(d.UserId, d.Method, d.Name)).Delete(r.Ctx); err != nil {
+		return errors.SystemError{UserId: r.SyncUserId, Context: "RemoveDestination", Log: fmt.Sprintf("could not remove %s destination %s: %s", method, name, err)}
+	}
+	return nil
+}
+
+func ListDestinations(r Requester, method string) ([]Destination, error) {
+	iter := r.Client.Collection(destinationCollection).Where("u", "==", r.SyncUserId).Where("m", "==", method).Documents(r.Ctx)
+	defer iter.Stop()
+	var ds []Destination
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.SystemError{UserId: r.SyncUserId, Context: "ListDestinations", Log: fmt.Sprintf("could not list %s destinations: %s", method, err)}
+		}
+		var d Destination
+		if err = doc.DataTo(&d); err != nil {
+			return nil, errors.SystemError{UserId: r.SyncUserId, Context: "ListDestinations", Log: fmt.Sprintf("could not read %s destination: %s", method, err)}
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// findDestination picks the destination named by the user, or their only one when no name was spoken
+func findDestination(r Requester, method, name string) (Destination, error) {
+	ds, err := ListDestinations(r, method)
+	if err != nil {
+		return Destination{}, err
+	}
+	if name == "" && len(ds) == 1 {
+		return ds[0], nil
+	}
+	names := make([]string, len(ds))
+	for i, d := range ds {
+		names[i] = d.Name
+	}
+	if name != "" {
+		if best, score := job_names.Match(name, names); score == 1 {
+			for _, d := range ds {
+				if d.Name == best {
+					return d, nil
+				}
+			}
+		}
+	}
+	return Destination{}, errors.DestinationNotFoundError{ContextualError: errors.ContextualError{UserId: r.SyncUserId, Context: "findDestination", Log: fmt.Sprintf("no %s destination matching %q among %d", method, name, len(ds))}, Method: method, Target: name, Available: names}
+}
+
+func destinationKey(syncUserId, method, name string) string {
+	return syncUserId + "_" + method + "_" + name
+}
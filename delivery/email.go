@@ -0,0 +1,51 @@
+package delivery
+
+import (
+	"fmt"
+	"regexp"
+	"speechLiason/cloud_resources"
+	"speechLiason/errors"
+)
+
+func init() {
+	Register(Email{})
+}
+
+type Email struct{}
+
+func (Email) Name() string {
+	return "email"
+}
+
+func (Email) ValidateDestination(destination string) error {
+	if !isValidEmail(destination) {
+		return errors.InvalidInputError{ContextualError: errors.ContextualError{Context: "ValidateDestination", Log: fmt.Sprintf("invalid email address %s", destination)}, ErroneousInput: "email address"}
+	}
+	return nil
+}
+
+// ResolveDestination sends to a contact when one was named, otherwise to the user's own profile email
+func (Email) ResolveDestination(r Requester) (Destination, error) {
+	if r.Target != "" {
+		return contactDestination(r, "email")
+	}
+	email, err := cloud_resources.GetUserEmail(r.ApiToken, r.VoiceUserId)
+	if err != nil {
+		return Destination{}, err
+	}
+	return Destination{UserId: r.SyncUserId, Method: "email", Address: email}, nil
+}
+
+// most mail servers turn away messages much over this
+func (Email) MaxAttachmentMB() int {
+	return 20
+}
+
+func (Email) Payload(d Destination) map[string]interface{} {
+	return nil
+}
+
+func isValidEmail(email string) bool {
+	re := regexp.MustCompile(`^"[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}"$`)
+	return re.MatchString(email)
+}
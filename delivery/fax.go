@@ -0,0 +1,45 @@
+package delivery
+
+import (
+	"fmt"
+	"regexp"
+	"speechLiason/errors"
+)
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+func init() {
+	Register(Fax{})
+}
+
+// Fax sends a job to a stored fax number, kept in E.164 form
+type Fax struct{}
+
+func (Fax) Name() string {
+	return "fax"
+}
+
+func (Fax) ValidateDestination(address string) error {
+	if !e164.MatchString(address) {
+		return errors.InvalidInputError{ContextualError: errors.ContextualError{Context: "ValidateDestination", Log: fmt.Sprintf("invalid fax number %s", address)}, ErroneousInput: "fax number"}
+	}
+	return nil
+}
+
+// ResolveDestination prefers a contact's number, then falls back to fax numbers registered on the account
+func (Fax) ResolveDestination(r Requester) (Destination, error) {
+	if r.Target != "" {
+		d, err := contactDestination(r, "fax")
+		if _, missing := err.(errors.ContactNotFoundError); !missing {
+			return d, err
+		}
+	}
+	return findDestination(r, "fax", r.Target)
+}
+
+func (Fax) Payload(d Destination) map[string]interface{} {
+	return map[string]interface{}{
+		"number":    d.Address,
+		"recipient": d.Name,
+	}
+}
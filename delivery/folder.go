@@ -0,0 +1,100 @@
+package delivery
+
+import (
+	"fmt"
+	"path"
+	"speechLiason/errors"
+	"strings"
+)
+
+const ProviderS3 = "s3"
+
+// fields on a folder destination; the address is the folder path, which for s3 starts with the bucket
+const (
+	ProviderField      = "provider"
+	CredentialRefField = "credentialRef"
+	EndpointField      = "endpoint"
+)
+
+func init() {
+	Register(Folder{})
+}
+
+type Folder struct{}
+
+func (Folder) Name() string {
+	return "folder"
+}
+
+func (Folder) ValidateDestination(address string) error {
+	if address == "" || strings.HasPrefix(address, "/") || path.Clean(address) != address || strings.Contains(address, "..") {
+		return errors.InvalidInputError{ContextualError: errors.ContextualError{Context: "ValidateDestination", Log: fmt.Sprintf("invalid folder path %s", address)}, ErroneousInput: "folder path"}
+	}
+	return nil
+}
+
+func (Folder) ResolveDestination(r Requester) (Destination, error) {
+	d, err := findDestination(r, "folder", r.Target)
+	if err != nil {
+		return d, err
+	}
+	if _, ok := folderStores[d.Fields[ProviderField]]; !ok {
+		return d, unsupportedProvider(d, "ResolveDestination")
+	}
+	if d.Fields[CredentialRefField] == "" {
+		return d, errors.InvalidInputError{ContextualError: errors.ContextualError{UserId: r.SyncUserId, Context: "ResolveDestination", Log: fmt.Sprintf("folder %s has no credential reference", d.Name)}, ErroneousInput: "folder credentials"}
+	}
+	return d, nil
+}
+
+// PrepareDestination keeps folders to providers we can write to, and points them at the credentials
+// the user will enter on the dashboard
+func (Folder) PrepareDestination(d Destination) (Destination, error) {
+	if d.Fields == nil {
+		d.Fields = map[string]string{}
+	}
+	if d.Fields[ProviderField] == "" {
+		d.Fields[ProviderField] = ProviderS3
+	}
+	if _, ok := folderStores[d.Fields[ProviderField]]; !ok {
+		return d, unsupportedProvider(d, "PrepareDestination")
+	}
+	if d.Fields[CredentialRefField] == "" {
+		d.Fields[CredentialRefField] = "folder/" + d.UserId + "/" + d.Name
+	}
+	return d, nil
+}
+
+// SpokenFolder puts together a folder path read out like "scans bucket slash tax receipts",
+// hyphenating words said together as bucket and folder names can't hold spaces
+func SpokenFolder(spoken string) string {
+	var b strings.Builder
+	word := false
+	for _, w := range strings.Fields(strings.ToLower(spoken)) {
+		if symbol, ok := spokenSymbols[w]; ok {
+			b.WriteString(symbol)
+			word = false
+			continue
+		}
+		if word {
+			b.WriteString("-")
+		}
+		b.WriteString(w)
+		word = true
+	}
+	return b.String()
+}
+
+func (Folder) Payload(d Destination) map[string]interface{} {
+	return map[string]interface{}{
+		ProviderField:      d.Fields[ProviderField],
+		"path":             d.Address,
+		CredentialRefField: d.Fields[CredentialRefField],
+		EndpointField:      d.Fields[EndpointField],
+	}
+}
+
+func unsupportedProvider(d Destination, context string) error {
+	p := d.Fields[ProviderField]
+	return errors.UnsupportedOperationError{ContextualError: errors.ContextualError{UserId: d.UserId, Context: context, Log: fmt.Sprintf("folder %s has unsupported provider %s", d.Name, p)}, ErroneousOperation: p}
+}
@@ -0,0 +1,79 @@
+package delivery
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"io"
+	"path"
+	"speechLiason/errors"
+	"strings"
+)
+
+const defaultS3Region = "us-east-1"
+
+// FolderStore writes delivered files into a user's folder
+type FolderStore interface {
+	Put(name string, body io.ReadSeeker) error
+}
+
+// folderStores opens the store for each provider; folders can only be registered for providers listed here
+var folderStores = map[string]func(d Destination, creds *credentials.Credentials) (FolderStore, error){
+	ProviderS3: func(d Destination, creds *credentials.Credentials) (FolderStore, error) {
+		s, err := NewS3Store(d.Fields[EndpointField], d.Address, creds)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	},
+}
+
+// NewFolderStore opens the store behind a folder destination, using credentials already looked up from its reference
+func NewFolderStore(d Destination, creds *credentials.Credentials) (FolderStore, error) {
+	open, ok := folderStores[d.Fields[ProviderField]]
+	if !ok {
+		return nil, unsupportedProvider(d, "NewFolderStore")
+	}
+	return open(d, creds)
+}
+
+type S3Store struct {
+	Bucket string
+	Prefix string
+	client *s3.S3
+}
+
+// NewS3Store works against AWS when endpoint is empty, or any S3-compatible server at endpoint, using path-style addressing
+func NewS3Store(endpoint, folderPath string, creds *credentials.Credentials) (*S3Store, error) {
+	parts := strings.SplitN(folderPath, "/", 2)
+	if parts[0] == "" {
+		return nil, errors.InvalidInputError{ContextualError: errors.ContextualError{Context: "NewS3Store", Log: fmt.Sprintf("folder path %s has no bucket", folderPath)}, ErroneousInput: "folder path"}
+	}
+	c := aws.NewConfig().WithRegion(defaultS3Region).WithCredentials(creds)
+	if endpoint != "" {
+		c = c.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSession(c)
+	if err != nil {
+		return nil, errors.SystemError{Context: "NewS3Store", Log: fmt.Sprintf("could not create s3 session: %s", err)}
+	}
+	s := &S3Store{Bucket: parts[0], client: s3.New(sess)}
+	if len(parts) > 1 {
+		s.Prefix = parts[1]
+	}
+	return s, nil
+}
+
+func (s *S3Store) Put(name string, body io.ReadSeeker) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path.Join(s.Prefix, name)),
+		Body:   body,
+	})
+	if err != nil {
+		return errors.SystemError{Context: "Put", Log: fmt.Sprintf("could not put %s in bucket %s: %s", name, s.Bucket, err)}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package delivery
+
+import (
+	"bytes"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"speechLiason/errors"
+	"testing"
+)
+
+// s3StandIn accepts object uploads the way an S3-compatible server would, remembering what was put where
+type s3StandIn struct {
+	objects map[string][]byte
+	status  int
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	s.objects[r.URL.Path] = body
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestS3StorePutsIntoFolder(t *testing.T) {
+	standIn := &s3StandIn{objects: map[string][]byte{}}
+	srv := httptest.NewServer(standIn)
+	defer srv.Close()
+
+	d := Destination{UserId: "sync-1", Method: "folder", Name: "taxes", Address: "scans/tax/2026", Fields: map[string]string{ProviderField: ProviderS3, EndpointField: srv.URL}}
+	store, err := NewFolderStore(d, credentials.NewStaticCredentials("key", "secret", ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	page := []byte("%PDF-1.4 a scanned page")
+	if err = store.Put("receipts.pdf", bytes.NewReader(page)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, ok := standIn.objects["/scans/tax/2026/receipts.pdf"]
+	if !ok {
+		t.Fatalf("nothing put at the folder path; got %v", standIn.objects)
+	}
+	if !bytes.Equal(got, page) {
+		t.Errorf("put %q, want %q", got, page)
+	}
+}
+
+func TestS3StoreReportsRejectedPut(t *testing.T) {
+	srv := httptest.NewServer(&s3StandIn{objects: map[string][]byte{}, status: http.StatusForbidden})
+	defer srv.Close()
+
+	store, err := NewS3Store(srv.URL, "scans", credentials.NewStaticCredentials("key", "secret", ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = store.Put("receipts.pdf", bytes.NewReader([]byte("page")))
+	if _, ok := err.(errors.SystemError); !ok {
+		t.Errorf("got %v, want a SystemError", err)
+	}
+}
+
+func TestFolderOnlyRegistersImplementedProviders(t *testing.T) {
+	d, err := Folder{}.PrepareDestination(Destination{UserId: "sync-1", Method: "folder", Name: "taxes", Address: "scans/tax"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d.Fields[ProviderField] != ProviderS3 || d.Fields[CredentialRefField] != "folder/sync-1/taxes" {
+		t.Errorf("got fields %v", d.Fields)
+	}
+	for _, p := range []string{"dropbox", "drive"} {
+		_, err = Folder{}.PrepareDestination(Destination{Method: "folder", Name: "taxes", Address: "scans/tax", Fields: map[string]string{ProviderField: p}})
+		if _, ok := err.(errors.UnsupportedOperationError); !ok {
+			t.Errorf("%s: got %v, want an UnsupportedOperationError", p, err)
+		}
+		if _, err = NewFolderStore(Destination{Fields: map[string]string{ProviderField: p}}, nil); err == nil {
+			t.Errorf("%s: opened a store for an unimplemented provider", p)
+		}
+	}
+}
+
+func TestSpokenFolder(t *testing.T) {
+	cases := map[string]string{
+		"scans bucket slash tax receipts": "scans-bucket/tax-receipts",
+		"Scans Slash 2026":                "scans/2026",
+		"":                                "",
+	}
+	for spoken, want := range cases {
+		if got := SpokenFolder(spoken); got != want {
+			t.Errorf("SpokenFolder(%q) = %q, want %q", spoken, got, want)
+		}
+	}
+}
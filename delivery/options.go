@@ -0,0 +1,92 @@
+package delivery
+
+import (
+	"fmt"
+	"speechLiason/cloud_resources"
+	"speechLiason/errors"
+)
+
+const (
+	FormatPdf    = "pdf"
+	FormatImages = "images"
+)
+
+// the format choices a user can speak or keep as their default; zip and protected refine a format
+// rather than replace it
+const (
+	ChoicePdf       = "pdf"
+	ChoiceImages    = "images"
+	ChoiceZip       = "zip"
+	ChoiceProtected = "protected"
+)
+
+// Options tells the delivery worker how to package a job: one merged PDF or an image per page,
+// optionally zipped, split into parts no bigger than MaxAttachmentMB. PasswordRef locks the zip
+// when there is one, otherwise the PDF.
+type Options struct {
+	Format          string `firestore:"f"`
+	Zip             bool   `firestore:"z"`
+	PasswordRef     string `firestore:"k"`
+	MaxAttachmentMB int    `firestore:"x"`
+}
+
+// AttachmentLimiter is implemented by methods whose destinations cap the size of what they accept
+type AttachmentLimiter interface {
+	MaxAttachmentMB() int
+}
+
+// ResolveOptions layers a spoken choice over the user's default choice and limit, over what the method allows
+func ResolveOptions(choice string, prefs cloud_resources.UserPreferences, m Method) (Options, error) {
+	o := Options{Format: FormatPdf}
+	if l, ok := m.(AttachmentLimiter); ok {
+		o.MaxAttachmentMB = l.MaxAttachmentMB()
+	}
+	if prefs.MaxAttachmentMB > 0 && (o.MaxAttachmentMB == 0 || prefs.MaxAttachmentMB < o.MaxAttachmentMB) {
+		o.MaxAttachmentMB = prefs.MaxAttachmentMB
+	}
+	o.Zip = prefs.ZipDeliveries
+	protect := prefs.ProtectDeliveries
+	for _, c := range []string{prefs.DeliveryFormat, choice} {
+		if c == "" {
+			continue
+		}
+		if err := ValidateChoice(c); err != nil {
+			return o, err
+		}
+		switch c {
+		case ChoicePdf:
+			o.Format = FormatPdf
+		case ChoiceImages:
+			o.Format = FormatImages
+		case ChoiceZip:
+			o.Zip = true
+		case ChoiceProtected:
+			protect = true
+		}
+	}
+	if protect {
+		if prefs.PdfPasswordRef == "" {
+			return o, errors.PdfPasswordNotSetError{UserId: prefs.SyncUserId, Context: "ResolveOptions", Log: "protected delivery asked for with no password set up"}
+		}
+		o.PasswordRef = prefs.PdfPasswordRef
+		// loose images can't carry a password, so protecting them means zipping them
+		if o.Format == FormatImages {
+			o.Zip = true
+		}
+	}
+	return o, nil
+}
+
+// PdfPasswordRef is where the back end keeps the password a user's protected deliveries are locked with;
+// the password itself is set on the dashboard and never passes through here
+func PdfPasswordRef(syncUserId string) string {
+	return "pdfPassword/" + syncUserId
+}
+
+func ValidateChoice(choice string) error {
+	switch choice {
+	case ChoicePdf, ChoiceImages, ChoiceZip, ChoiceProtected:
+		return nil
+	}
+	return errors.InvalidInputError{ContextualError: errors.ContextualError{Context: "ValidateChoice", Log: fmt.Sprintf("unsupported delivery format %q", choice)}, ErroneousInput: "delivery format"}
+}
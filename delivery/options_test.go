@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"speechLiason/cloud_resources"
+	"speechLiason/errors"
+	"testing"
+)
+
+func TestResolveOptions(t *testing.T) {
+	ref := PdfPasswordRef("sync-1")
+	cases := []struct {
+		name   string
+		choice string
+		prefs  cloud_resources.UserPreferences
+		want   Options
+	}{
+		{"defaults", "", cloud_resources.UserPreferences{}, Options{Format: FormatPdf, MaxAttachmentMB: 20}},
+		{"spoken format", ChoiceImages, cloud_resources.UserPreferences{}, Options{Format: FormatImages, MaxAttachmentMB: 20}},
+		{"zip of images", ChoiceZip, cloud_resources.UserPreferences{DeliveryFormat: ChoiceImages}, Options{Format: FormatImages, Zip: true, MaxAttachmentMB: 20}},
+		{"protected zip", "", cloud_resources.UserPreferences{ZipDeliveries: true, ProtectDeliveries: true, PdfPasswordRef: ref}, Options{Format: FormatPdf, Zip: true, PasswordRef: ref, MaxAttachmentMB: 20}},
+		{"protected images are zipped", ChoiceProtected, cloud_resources.UserPreferences{DeliveryFormat: ChoiceImages, PdfPasswordRef: ref}, Options{Format: FormatImages, Zip: true, PasswordRef: ref, MaxAttachmentMB: 20}},
+		{"spoken format keeps default packaging", ChoiceImages, cloud_resources.UserPreferences{ZipDeliveries: true}, Options{Format: FormatImages, Zip: true, MaxAttachmentMB: 20}},
+		{"smaller user limit", "", cloud_resources.UserPreferences{MaxAttachmentMB: 5}, Options{Format: FormatPdf, MaxAttachmentMB: 5}},
+		{"larger user limit", "", cloud_resources.UserPreferences{MaxAttachmentMB: 50}, Options{Format: FormatPdf, MaxAttachmentMB: 20}},
+	}
+	for _, c := range cases {
+		got, err := ResolveOptions(c.choice, c.prefs, Email{})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestResolveOptionsNeedsPasswordToProtect(t *testing.T) {
+	for _, prefs := range []cloud_resources.UserPreferences{{}, {ProtectDeliveries: true}} {
+		_, err := ResolveOptions(ChoiceProtected, prefs, Email{})
+		if _, ok := err.(errors.PdfPasswordNotSetError); !ok {
+			t.Errorf("%+v: got %v, want a PdfPasswordNotSetError", prefs, err)
+		}
+	}
+	if _, err := ResolveOptions("tiff", cloud_resources.UserPreferences{}, Email{}); err == nil {
+		t.Error("expected an unsupported format to be rejected")
+	}
+}
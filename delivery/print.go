@@ -0,0 +1,41 @@
+package delivery
+
+import (
+	"fmt"
+	"speechLiason/errors"
+	"strings"
+)
+
+func init() {
+	Register(Print{})
+}
+
+// Print sends a job to a printer registered on the account; the address is the printer's id with the print service
+type Print struct{}
+
+func (Print) Name() string {
+	return "print"
+}
+
+func (Print) ValidateDestination(address string) error {
+	if address == "" || strings.ContainsAny(address, " \t\n") {
+		return errors.InvalidInputError{ContextualError: errors.ContextualError{Context: "ValidateDestination", Log: fmt.Sprintf("invalid printer id %q", address)}, ErroneousInput: "printer"}
+	}
+	return nil
+}
+
+func (Print) ResolveDestination(r Requester) (Destination, error) {
+	return findDestination(r, "print", r.Target)
+}
+
+// SpokenPrinterId joins up a printer id read out a character or group at a time
+func SpokenPrinterId(spoken string) string {
+	return strings.Join(strings.Fields(spoken), "")
+}
+
+func (Print) Payload(d Destination) map[string]interface{} {
+	return map[string]interface{}{
+		"printer":     d.Address,
+		"printerName": d.Name,
+	}
+}
@@ -0,0 +1,155 @@
+package delivery
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"speechLiason/errors"
+	"strings"
+)
+
+const SignatureHeader = "X-Speechliaison-Signature"
+
+// SigningKeyField names the destination field holding a reference to the signing secret, never the secret itself
+const SigningKeyField = "signingKeyRef"
+
+// allowLoopback lets tests register webhooks served by httptest; nothing outside tests should set it
+var allowLoopback = false
+
+var lookupIP = net.LookupIP
+
+// hosts that reach our own machine or a cloud metadata service, whatever they resolve to
+var blockedHosts = map[string]bool{"localhost": true, "metadata": true, "metadata.google.internal": true}
+
+// carrier-grade NAT space, which some clouds also use for internal services
+var _, sharedAddressSpace, _ = net.ParseCIDR("100.64.0.0/10")
+
+func init() {
+	Register(Webhook{})
+}
+
+type Webhook struct{}
+
+func (Webhook) Name() string {
+	return "webhook"
+}
+
+// ValidateDestination accepts https URLs whose host is on the public internet, since the delivery worker
+// calls them from inside our network and mustn't be pointed back at it
+func (Webhook) ValidateDestination(address string) error {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" || u.User != nil {
+		return invalidWebhook(address)
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && allowLoopback && isLoopback(u.Hostname())) {
+		return invalidWebhook(address)
+	}
+	if !isPublicHost(u.Hostname()) {
+		return invalidWebhook(address)
+	}
+	return nil
+}
+
+// PrepareDestination points a new webhook at the secret the back end will generate and sign its deliveries with
+func (Webhook) PrepareDestination(d Destination) (Destination, error) {
+	if d.Fields == nil {
+		d.Fields = map[string]string{}
+	}
+	if d.Fields[SigningKeyField] == "" {
+		d.Fields[SigningKeyField] = "webhook/" + d.UserId + "/" + d.Name
+	}
+	return d, nil
+}
+
+func (Webhook) ResolveDestination(r Requester) (Destination, error) {
+	d, err := findDestination(r, "webhook", r.Target)
+	if err != nil {
+		return d, err
+	}
+	if d.Fields[SigningKeyField] == "" {
+		return d, errors.InvalidInputError{ContextualError: errors.ContextualError{UserId: r.SyncUserId, Context: "ResolveDestination", Log: fmt.Sprintf("webhook %s has no signing key reference", d.Name)}, ErroneousInput: "webhook signing key"}
+	}
+	return d, nil
+}
+
+func (Webhook) Payload(d Destination) map[string]interface{} {
+	return map[string]interface{}{
+		"url":           d.Address,
+		SigningKeyField: d.Fields[SigningKeyField],
+		"header":        SignatureHeader,
+	}
+}
+
+// Sign is what the delivery worker puts in SignatureHeader so receivers can check a payload came from us
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// SpokenUrl puts together a URL read out word by word, like "hooks dot example dot com slash scans",
+// assuming https when no scheme was said
+func SpokenUrl(spoken string) string {
+	words := strings.Fields(strings.ToLower(spoken))
+	for i, w := range words {
+		if symbol, ok := spokenSymbols[w]; ok {
+			words[i] = symbol
+		}
+	}
+	u := strings.Join(words, "")
+	if u != "" && !strings.Contains(u, "://") {
+		u = "https://" + u
+	}
+	return u
+}
+
+var spokenSymbols = map[string]string{"dot": ".", "slash": "/", "colon": ":", "dash": "-", "hyphen": "-", "underscore": "_"}
+
+// isPublicHost resolves host and checks every address it could reach; loopback is let through only for tests
+func isPublicHost(host string) bool {
+	if allowLoopback && isLoopback(host) {
+		return true
+	}
+	if blockedHosts[strings.TrimSuffix(strings.ToLower(host), ".")] {
+		return false
+	}
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		if ips, err = lookupIP(host); err != nil || len(ips) == 0 {
+			return false
+		}
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	return !sharedAddressSpace.Contains(ip)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func invalidWebhook(address string) error {
+	return errors.InvalidInputError{ContextualError: errors.ContextualError{Context: "ValidateDestination", Log: fmt.Sprintf("invalid webhook url %s", address)}, ErroneousInput: "webhook address"}
+}
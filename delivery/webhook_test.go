@@ -0,0 +1,142 @@
+package delivery
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookRejectsInternalAddresses(t *testing.T) {
+	defer stubLookup(map[string][]net.IP{
+		"hooks.example.com":    {net.ParseIP("93.184.216.34")},
+		"internal.example.com": {net.ParseIP("10.1.2.3")},
+		"mixed.example.com":    {net.ParseIP("93.184.216.34"), net.ParseIP("169.254.169.254")},
+	})()
+	cases := []struct {
+		address string
+		valid   bool
+	}{
+		{"https://hooks.example.com/scans", true},
+		{"https://93.184.216.34/scans", true},
+		{"http://hooks.example.com/scans", false},
+		{"https://user@hooks.example.com/scans", false},
+		{"https://internal.example.com/scans", false},
+		{"https://mixed.example.com/scans", false},
+		{"https://unresolvable.example.com/scans", false},
+		{"https://localhost/scans", false},
+		{"http://127.0.0.1:8080/scans", false},
+		{"https://127.0.0.1/scans", false},
+		{"https://[::1]/scans", false},
+		{"https://10.0.0.5/scans", false},
+		{"https://172.16.4.1/scans", false},
+		{"https://192.168.1.1/scans", false},
+		{"https://100.100.100.200/scans", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://[fd00:ec2::254]/scans", false},
+		{"https://metadata.google.internal/computeMetadata/v1", false},
+		{"https://0.0.0.0/scans", false},
+	}
+	for _, c := range cases {
+		err := Webhook{}.ValidateDestination(c.address)
+		if c.valid && err != nil {
+			t.Errorf("%s: unexpected error: %s", c.address, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%s: expected it to be rejected", c.address)
+		}
+	}
+}
+
+func TestWebhookLoopbackOnlyAllowedForTests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if err := (Webhook{}).ValidateDestination(srv.URL); err == nil {
+		t.Fatalf("%s was accepted without the test hook", srv.URL)
+	}
+	allowLoopback = true
+	defer func() { allowLoopback = false }()
+	if err := (Webhook{}).ValidateDestination(srv.URL); err != nil {
+		t.Fatalf("%s was rejected with the test hook: %s", srv.URL, err)
+	}
+}
+
+func TestSignedWebhookVerifiesAtReceiver(t *testing.T) {
+	secret := []byte("receiver secret")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !VerifySignature(secret, body, r.Header.Get(SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	allowLoopback = true
+	defer func() { allowLoopback = false }()
+
+	d, err := Webhook{}.PrepareDestination(Destination{UserId: "sync-1", Method: "webhook", Name: "office", Address: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = (Webhook{}).ValidateDestination(d.Address); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p := Webhook{}.Payload(d)
+	if p[SigningKeyField] != "webhook/sync-1/office" {
+		t.Errorf("signing key reference is %q", p[SigningKeyField])
+	}
+	body := []byte(`{"job":"taxes","pages":3}`)
+	cases := []struct {
+		name   string
+		secret []byte
+		sent   []byte
+		status int
+	}{
+		{"signed", secret, body, http.StatusNoContent},
+		{"wrong secret", []byte("someone else"), body, http.StatusUnauthorized},
+		{"tampered body", secret, []byte(`{"job":"taxes","pages":30}`), http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodPost, p["url"].(string), bytes.NewReader(c.sent))
+		req.Header.Set(p["header"].(string), Sign(c.secret, body))
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != c.status {
+			t.Errorf("%s: receiver answered %d, want %d", c.name, res.StatusCode, c.status)
+		}
+	}
+}
+
+func TestSpokenUrl(t *testing.T) {
+	cases := map[string]string{
+		"hooks dot example dot com slash scans":                    "https://hooks.example.com/scans",
+		"https colon slash slash my dash server dot net slash in":  "https://my-server.net/in",
+		"Hooks Dot Example Dot Com Slash Scan Underscore Receiver": "https://hooks.example.com/scan_receiver",
+		"": "",
+	}
+	for spoken, want := range cases {
+		if got := SpokenUrl(spoken); got != want {
+			t.Errorf("SpokenUrl(%q) = %q, want %q", spoken, got, want)
+		}
+	}
+}
+
+// stubLookup answers name lookups from hosts, failing for anything else, and returns a func restoring the real resolver
+func stubLookup(hosts map[string][]net.IP) func() {
+	resolver := lookupIP
+	lookupIP = func(host string) ([]net.IP, error) {
+		if ips, ok := hosts[host]; ok {
+			return ips, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	return func() { lookupIP = resolver }
+}
@@ -52,15 +52,98 @@ func (e MissingJobNameError) Error() string {
 	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
 }
 
+type JobNotFoundError ContextualError
+
+func (e JobNotFoundError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type JobExistsError ContextualError
+
+func (e JobExistsError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type NothingToUndoError ContextualError
+
+func (e NothingToUndoError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type NothingToRetryError ContextualError
+
+func (e NothingToRetryError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type NoDeliveriesError ContextualError
+
+func (e NoDeliveriesError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type DeliveryTimePassedError ContextualError
+
+func (e DeliveryTimePassedError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type PdfPasswordNotSetError ContextualError
+
+func (e PdfPasswordNotSetError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type DigestRuleNotFoundError ContextualError
+
+func (e DigestRuleNotFoundError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type NothingToCancelError ContextualError
+
+func (e NothingToCancelError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type NoPreviousJobError ContextualError
+
+func (e NoPreviousJobError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
 type UnsupportedOperationError struct {
 	ContextualError
 	ErroneousOperation string
+	Available          []string
 }
 
 func (e UnsupportedOperationError) Error() string {
 	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
 }
 
+type DestinationNotFoundError struct {
+	ContextualError
+	Method    string
+	Target    string
+	Available []string
+}
+
+func (e DestinationNotFoundError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type ContactNotFoundError struct {
+	ContextualError
+	Contact       string
+	MissingMethod string
+	Available     []string
+}
+
+func (e ContactNotFoundError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
 type InvalidInputError struct {
 	ContextualError
 	ErroneousInput string
@@ -70,6 +153,26 @@ func (e InvalidInputError) Error() string {
 	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
 }
 
+type LinkedAccountNotFoundError struct {
+	ContextualError
+	AccountName string
+	Available   []string
+}
+
+func (e LinkedAccountNotFoundError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
+type UncertainJobNameError struct {
+	ContextualError
+	SpokenName string
+	Candidate  string
+}
+
+func (e UncertainJobNameError) Error() string {
+	return fmt.Sprintf("[ERROR] UserId %s, JobName %s, Context %s, Log %s", e.UserId, e.JobName, e.Context, e.Log)
+}
+
 type SystemError ContextualError
 
 func (e SystemError) Error() string {
@@ -80,6 +183,12 @@ func AnalyzeError(e error, syncUserId, jobName string) (r alexa.Response) {
 	log.Print(e.Error())
 	switch e.(type) {
 	case CursorExpiredError:
+		if x := e.(CursorExpiredError); x.JobName != "" {
+			r = respond.Questioningly("It's been a while since your last scan.  Your last job was "+x.JobName+"; do you want to keep using it?", "resumeJob", syncUserId, "")
+			r.SessionAttributes["matchedJob"] = x.JobName
+			r.SessionAttributes["spokenJob"] = ""
+			break
+		}
 		r = respond.Openly("It's been a while since your last scan, and you'll need to specify a job first.  You can create a new job, or use a previous job by telling me to use the job you have in mind, or telling me to scan a page to that job.  Just tell me which you'd like to do.", false, syncUserId, jobName)
 		break
 	case CursorNotFoundError:
@@ -92,17 +201,89 @@ func AnalyzeError(e error, syncUserId, jobName string) (r alexa.Response) {
 		r = respond.Openly("While I was able to find a matching code to the one you spoke, it has unfortunately expired.  Please click cancel, and retry the sync process while making sure to speak the code given within a few minutes.", false, syncUserId, jobName)
 		break
 	case UserAccountNotSyncedError:
-		r = respond.Openly("You have not yet synced your Echo device to your Reborne account.  Please open the Reborne dashboard in your browser, and start the sync process by clicking on the user icon on the screen.", false, "", jobName)
+		r = respond.LinkAccount("You have not yet linked your Echo device to your Reborne account.  I've sent a card to the Alexa app where you can link it, or you can open the Reborne dashboard in your browser and start the sync process by clicking on the user icon on the screen.", false)
 		break
 	case MissingJobNameError:
 		r = respond.Openly("You'll need to specify a job first.  You can create a new job, or tell me to scan a page to a job.  Just tell me which you'd like to do.", false, syncUserId, jobName)
 		break
+	case JobNotFoundError:
+		r = respond.Openly("I don't have a job called "+e.(JobNotFoundError).JobName+".  You can ask me what your jobs are.", false, syncUserId, jobName)
+		break
+	case JobExistsError:
+		r = respond.Openly("You already have a job called "+e.(JobExistsError).JobName+".  You can merge the two jobs instead.", false, syncUserId, jobName)
+		break
+	case NothingToUndoError:
+		r = respond.Openly("There's no recent scan in the job "+jobName+" for me to undo.", false, syncUserId, jobName)
+		break
+	case NothingToRetryError:
+		r = respond.Openly("Nothing failed in your last delivery, so there's nothing to retry.", false, syncUserId, jobName)
+		break
+	case NoDeliveriesError:
+		r = respond.Openly("You haven't sent any deliveries yet.", false, syncUserId, jobName)
+		break
+	case DeliveryTimePassedError:
+		r = respond.Openly("That time has already passed.  When would you like me to send it?", false, syncUserId, jobName)
+		break
+	case PdfPasswordNotSetError:
+		r = respond.Openly("You haven't set up a PDF password on your account yet, so I can't protect the file.  You can ask me to set up a PDF password first.", false, syncUserId, jobName)
+		break
+	case DigestRuleNotFoundError:
+		r = respond.Openly("I couldn't find a digest like that to remove.", false, syncUserId, jobName)
+		break
+	case NothingToCancelError:
+		r = respond.Openly("There's nothing waiting to be scanned or delivered that I can cancel.", false, syncUserId, jobName)
+		break
+	case NoPreviousJobError:
+		r = respond.Openly("I don't have a previous job for you to go back to.  You can create a new job, or tell me to scan a page to an existing one.", false, syncUserId, jobName)
+		break
 	case UnsupportedOperationError:
-		r = respond.Openly("Unfortunately, I can't deliver your job in the method you've selected yet.  Try asking me to email the job instead.", false, syncUserId, jobName)
+		if a := e.(UnsupportedOperationError).Available; len(a) > 0 {
+			r = respond.Openly("Unfortunately, I can't deliver your job in the method you've selected yet.  I can deliver by "+respond.Enumerate(a)+".", false, syncUserId, jobName)
+			break
+		}
+		r = respond.Openly("Unfortunately, I can't deliver your job in the method you've selected yet.", false, syncUserId, jobName)
+		break
+	case DestinationNotFoundError:
+		d := e.(DestinationNotFoundError)
+		if len(d.Available) == 0 {
+			r = respond.Openly("You haven't set up any "+d.Method+" destinations on your account yet.", false, syncUserId, jobName)
+			break
+		}
+		if d.Target == "" {
+			r = respond.Openly("Which "+d.Method+" destination should I use?  You have "+respond.Enumerate(d.Available)+".", false, syncUserId, jobName)
+			break
+		}
+		r = respond.Openly("I don't see a "+d.Method+" destination called "+d.Target+".  You have "+respond.Enumerate(d.Available)+".", false, syncUserId, jobName)
+		break
+	case ContactNotFoundError:
+		c := e.(ContactNotFoundError)
+		if c.MissingMethod != "" {
+			r = respond.Openly("I don't have a "+c.MissingMethod+" address for "+c.Contact+".", false, syncUserId, jobName)
+			break
+		}
+		if len(c.Available) == 0 {
+			r = respond.Openly("I don't see "+c.Contact+" in your contacts, and you haven't added any yet.", false, syncUserId, jobName)
+			break
+		}
+		r = respond.Openly("I don't see "+c.Contact+" in your contacts.  Your contacts are "+respond.Enumerate(c.Available)+".", false, syncUserId, jobName)
 		break
 	case InvalidInputError:
+		if i := e.(InvalidInputError).ErroneousInput; i != "" && i != "email address" {
+			r = respond.Openly("The "+i+" you've given isn't valid.  Please try again.", false, syncUserId, jobName)
+			break
+		}
 		r = respond.Openly("The email you've chosen for delivery isn't valid.  Try again with a valid email address", false, syncUserId, jobName)
 		break
+	case LinkedAccountNotFoundError:
+		a := e.(LinkedAccountNotFoundError)
+		r = respond.Openly("I don't see a linked account called "+a.AccountName+".  Your linked accounts are "+respond.Enumerate(a.Available)+".", false, syncUserId, jobName)
+		break
+	case UncertainJobNameError:
+		u := e.(UncertainJobNameError)
+		r = respond.Questioningly("I couldn't find a job called "+u.SpokenName+".  Did you mean "+u.Candidate+"?", "jobMatch", syncUserId, "")
+		r.SessionAttributes["matchedJob"] = u.Candidate
+		r.SessionAttributes["spokenJob"] = u.SpokenName
+		break
 	default:
 		r = respond.Openly("There was a problem attempting your request; please try again later", false, syncUserId, jobName)
 		break
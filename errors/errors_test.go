@@ -0,0 +1,15 @@
+package errors
+
+import (
+	"testing"
+)
+
+func TestAnalyzeErrorSendsLinkAccountCardWhenNotLinked(t *testing.T) {
+	r := AnalyzeError(UserAccountNotSyncedError{Context: "getUserId", Log: "no mapping and no access token"}, "", "")
+	if r.Body.Card == nil || r.Body.Card.Type != "LinkAccount" {
+		t.Fatalf("got card %+v, want a LinkAccount card", r.Body.Card)
+	}
+	if r.Body.ShouldEndSession {
+		t.Error("session ended; the user should be able to carry on")
+	}
+}
@@ -0,0 +1,175 @@
+package job_names
+
+import (
+	"strings"
+	"unicode"
+)
+
+// spoken names scoring at least this against an existing job are worth asking about
+const PossibleMatch = 0.6
+
+// phonetically identical names score at least this, so "tax docs" and "tax dox" are always offered
+const phoneticMatch = 0.8
+
+func Canonicalize(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		if unicode.IsSpace(r) || r == '-' || r == '_' {
+			return ' '
+		}
+		return -1
+	}, name)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
+
+// Match finds the existing job closest to the spoken name; a score of 1 means they're
+// the same job once canonicalized
+func Match(spoken string, existing []string) (best string, score float64) {
+	s := Canonicalize(spoken)
+	if s == "" {
+		return "", 0
+	}
+	sp, sn := phonetic(s), numbers(s)
+	for _, e := range existing {
+		c := Canonicalize(e)
+		if c == s {
+			return e, 1
+		}
+		// "2019 taxes" and "2018 taxes" are different jobs however alike they sound
+		if numbers(c) != sn {
+			continue
+		}
+		n := similarity(s, c)
+		if n < phoneticMatch && phonetic(c) == sp {
+			n = phoneticMatch
+		}
+		if n > score {
+			best, score = e, n
+		}
+	}
+	return
+}
+
+// Synonyms gives the other ways a job name is likely to be spoken
+func Synonyms(name string) []string {
+	c := Canonicalize(name)
+	if c == "" {
+		return nil
+	}
+	candidates := []string{c, c + " job", strings.Replace(c, " ", "", -1)}
+	seen := map[string]bool{name: true, "": true}
+	var result []string
+	for _, s := range candidates {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for k := range previous {
+		previous[k] = k
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for k := 1; k <= len(b); k++ {
+			cost := 1
+			if a[i-1] == b[k-1] {
+				cost = 0
+			}
+			current[k] = smallest(previous[k]+1, current[k-1]+1, previous[k-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func smallest(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// numbers picks out the words of a canonical name holding digits
+func numbers(name string) string {
+	var n []string
+	for _, w := range strings.Fields(name) {
+		if strings.IndexFunc(w, unicode.IsDigit) >= 0 {
+			n = append(n, w)
+		}
+	}
+	return strings.Join(n, " ")
+}
+
+// phonetic builds a soundex key for each word of a canonical name
+func phonetic(name string) string {
+	words := strings.Fields(name)
+	keys := make([]string, len(words))
+	for i, w := range words {
+		keys[i] = soundex(w)
+	}
+	return strings.Join(keys, " ")
+}
+
+func soundex(word string) string {
+	codes := map[rune]byte{
+		'b': '1', 'f': '1', 'p': '1', 'v': '1',
+		'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+		'd': '3', 't': '3',
+		'l': '4',
+		'm': '5', 'n': '5',
+		'r': '6',
+	}
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return ""
+	}
+	// digits are kept as-is, so "2019" and "2018" stay distinct
+	if unicode.IsDigit(runes[0]) {
+		return word
+	}
+	key := []byte{byte(unicode.ToUpper(runes[0]))}
+	last := codes[runes[0]]
+	for _, r := range runes[1:] {
+		c, ok := codes[r]
+		if !ok {
+			if r != 'h' && r != 'w' {
+				last = 0
+			}
+			continue
+		}
+		if c != last {
+			key = append(key, c)
+		}
+		last = c
+		if len(key) == 4 {
+			break
+		}
+	}
+	for len(key) < 4 {
+		key = append(key, '0')
+	}
+	return string(key)
+}
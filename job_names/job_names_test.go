@@ -0,0 +1,122 @@
+package job_names
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	cases := map[string]string{
+		"Tax Docs":        "tax docs",
+		"  tax   docs  ":  "tax docs",
+		"Tax-Docs":        "tax docs",
+		"tax_docs":        "tax docs",
+		"Bob's Receipts!": "bobs receipts",
+		"2019 Taxes":      "2019 taxes",
+		"Café Menus":      "café menus",
+		"?!":              "",
+		"":                "",
+	}
+	for name, want := range cases {
+		if got := Canonicalize(name); got != want {
+			t.Errorf("Canonicalize(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		spoken   string
+		existing []string
+		best     string
+		score    float64
+	}{
+		// the same job once canonicalized, returned as stored
+		{"tax docs", []string{"receipts", "Tax Docs"}, "Tax Docs", 1},
+		{"TAX-DOCS", []string{"tax docs"}, "tax docs", 1},
+		{"tax docs", []string{"tax dox", "tax docs"}, "tax docs", 1},
+		// phonetically identical names are always offered
+		{"tax docs", []string{"tax dox"}, "tax dox", phoneticMatch},
+		{"taxes", []string{"faxes"}, "faxes", 0.8},
+		// close but distinct names are offered rather than taken
+		{"receipts", []string{"recipes"}, "recipes", 0.75},
+		{"tax", []string{"fax"}, "fax", 2.0 / 3},
+		{"books", []string{"boats"}, "boats", PossibleMatch},
+		// numbers have to agree
+		{"2019 taxes", []string{"2018 taxes"}, "", 0},
+		{"taxes", []string{"2019 taxes"}, "", 0},
+		{"2019 taxes", []string{"2018 taxes", "2019 taxs"}, "2019 taxs", 0.9},
+		// short names that share nothing
+		{"ab", []string{"ac"}, "ac", 0.5},
+		{"a", []string{"b"}, "", 0},
+		{"medical", []string{"school"}, "school", 1 - 6.0/7},
+		{"", []string{"tax docs"}, "", 0},
+		{"tax docs", nil, "", 0},
+	}
+	for _, c := range cases {
+		best, score := Match(c.spoken, c.existing)
+		if best != c.best || !near(score, c.score) {
+			t.Errorf("Match(%q, %q) = %q %.3f, want %q %.3f", c.spoken, c.existing, best, score, c.best, c.score)
+		}
+	}
+}
+
+func TestMatchThresholds(t *testing.T) {
+	cases := []struct {
+		spoken, existing string
+		exact, possible  bool
+	}{
+		{"Tax Docs", "tax docs", true, true},
+		{"tax docs", "tax dox", false, true},
+		{"books", "boats", false, true},
+		{"receipts", "recipes", false, true},
+		{"ab", "ac", false, false},
+		{"2019 taxes", "2018 taxes", false, false},
+		{"medical", "school", false, false},
+	}
+	for _, c := range cases {
+		_, score := Match(c.spoken, []string{c.existing})
+		if exact := score == 1; exact != c.exact {
+			t.Errorf("%q against %q scored %.3f; exact match %t, want %t", c.spoken, c.existing, score, exact, c.exact)
+		}
+		if possible := score >= PossibleMatch; possible != c.possible {
+			t.Errorf("%q against %q scored %.3f; possible match %t, want %t", c.spoken, c.existing, score, possible, c.possible)
+		}
+	}
+}
+
+func TestSynonyms(t *testing.T) {
+	cases := map[string][]string{
+		"Tax Docs": {"tax docs", "tax docs job", "taxdocs"},
+		"receipts": {"receipts job"},
+		"":         nil,
+	}
+	for name, want := range cases {
+		if got := Synonyms(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("Synonyms(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSoundex(t *testing.T) {
+	cases := map[string]string{
+		"robert":   "R163",
+		"rupert":   "R163",
+		"ashcraft": "A261",
+		"tax":      "T200",
+		"fax":      "F200",
+		"a":        "A000",
+		"2019":     "2019",
+		"":         "",
+	}
+	for word, want := range cases {
+		if got := soundex(word); got != want {
+			t.Errorf("soundex(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func near(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
@@ -1,19 +1,104 @@
 package main
 
 import (
+	stderrors "errors"
 	"fmt"
 	"github.com/arienmalec/alexa-go"
 	"github.com/aws/aws-lambda-go/lambda"
 	"os"
+	"speechLiason/account_link"
 	"speechLiason/cloud_resources"
+	"speechLiason/delivery"
 	"speechLiason/errors"
+	"speechLiason/job_names"
 	"speechLiason/key_access"
 	"speechLiason/queue_connect"
 	"speechLiason/respond"
+	"speechLiason/voice_request"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const syncAcceptanceWait = 6 * time.Second
+const jobListPageSize = 3
+const jobEntityCount = 20
+
 func main() {
-	lambda.Start(DispatchIntents)
+	if os.Getenv("INVOCATION_MODE") == "scheduler" {
+		lambda.Start(HandleSchedule)
+		return
+	}
+	lambda.Start(HandleRequest)
+}
+
+// HandleSchedule runs on a timer, outside of any voice session, to release deliveries and run digests that are due
+func HandleSchedule() error {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return err
+	}
+	defer queue_connect.CloseConnection()
+	now := time.Now()
+	// neither step should hold up the other, but either failing fails the run so it gets noticed
+	released, releaseErr := queue_connect.ReleaseDueDeliveries(now)
+	_, _ = fmt.Fprintf(os.Stdout, "released %d scheduled deliveries\n", released)
+	emitted, digestErr := queue_connect.RunDueDigests(now)
+	_, _ = fmt.Fprintf(os.Stdout, "emitted %d digest deliveries\n", emitted)
+	return stderrors.Join(releaseErr, digestErr)
+}
+
+func HandleRequest(request voice_request.Request) (respond.Response, error) {
+	if !refreshesJobEntities(request) {
+		r, err := DispatchIntents(request)
+		return respond.Wrap(r), err
+	}
+	// holding the connection across dispatch lets the handler and the entity lookup share it
+	key := key_access.GetKey()
+	connected := queue_connect.InitConnection("reborne", key) == nil
+	if connected {
+		defer queue_connect.CloseConnection()
+	}
+	r, err := DispatchIntents(request)
+	if err != nil || r.Body.ShouldEndSession || !connected {
+		return respond.Wrap(r), err
+	}
+	syncUserId, _ := r.SessionAttributes["syncUserId"].(string)
+	if syncUserId == "" {
+		return respond.Wrap(r), nil
+	}
+	return respond.WithDynamicEntities(r, "JobName", jobEntities(syncUserId)), nil
+}
+
+// refreshesJobEntities is true when Alexa hasn't heard the user's jobs yet this session, or the request can change them
+func refreshesJobEntities(request voice_request.Request) bool {
+	if request.Session.New {
+		return true
+	}
+	switch request.Body.Intent.Name {
+	case "createJob", "scan", "renameJob", "deleteJob", "mergeJobs":
+		return true
+	case "AMAZON.YesIntent", "AMAZON.NoIntent":
+		switch request.SessionString("pendingConfirmation") {
+		case "jobMatch", "resumeJob", "deleteJob":
+			return true
+		}
+	}
+	return false
+}
+
+// jobEntities teaches Alexa the user's job vocabulary; failing to build them shouldn't fail the request
+func jobEntities(syncUserId string) []respond.DynamicEntity {
+	names, err := queue_connect.RecentJobNames(syncUserId, jobEntityCount)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	entities := make([]respond.DynamicEntity, len(names))
+	for i, n := range names {
+		entities[i] = respond.DynamicEntity{Id: job_names.Canonicalize(n), Value: n, Synonyms: job_names.Synonyms(n)}
+	}
+	return entities
 }
 
 /*
@@ -23,35 +108,93 @@ TODO:
 - figure out better way to give code without multiple prompts
 */
 
-func DispatchIntents(request alexa.Request) (alexa.Response, error) {
+func DispatchIntents(request voice_request.Request) (alexa.Response, error) {
 	var s string
 	var p string
-	u := request.Session.User.UserID
-	if request.Session.Attributes["syncUserId"] != nil {
-		s = request.Session.Attributes["syncUserId"].(string)
-	}
-	if request.Session.Attributes["previousJobCursor"] != nil {
-		p = request.Session.Attributes["previousJobCursor"].(string)
+	u := request.SpeakerId()
+	s = request.SessionString("syncUserId")
+	p = request.SessionString("previousJobCursor")
+	if t := request.Session.User.AccessToken; request.Session.New && t != "" {
+		// account linking maps the whole Amazon account; recognized speakers fall back to it
+		if _, err := account_link.LinkFromAccessToken(request.Session.User.UserID, t); err != nil {
+			fmt.Println(err)
+		}
 	}
 	var r alexa.Response
 	fmt.Print("request body", request.Body)
 	fmt.Print("request session", request.Session)
+	if request.Body.Intent.ConfirmationStatus == voice_request.ConfirmationDenied {
+		return respond.Openly("Okay, I'll leave things as they are.", false, s, p), nil
+	}
 	switch request.Body.Intent.Name {
 	case "sync":
-		c := request.Body.Intent.Slots["spokenCode"].Value
+		cs := request.Slot("spokenCode")
+		if cs.Unrecognized() {
+			r = unrecognizedCode()
+			break
+		}
+		c := cs.Value()
+		t := request.Context.System.APIAccessToken
+		d := request.Context.System.Device.DeviceID
+		a := accountName(request.Body.Intent.Slots["accountName"].Value)
+		r = Sync(c, a, u, t, d)
+		break
+	case "startSync":
+		t := request.Context.System.APIAccessToken
+		d := request.Context.System.Device.DeviceID
+		a := accountName(request.Body.Intent.Slots["accountName"].Value)
+		r = StartSync(a, u, t, d)
+		break
+	case "resync":
+		cs := request.Slot("spokenCode")
+		if cs.Unrecognized() {
+			r = unrecognizedCode()
+			break
+		}
+		c := cs.Value()
 		t := request.Context.System.APIAccessToken
 		d := request.Context.System.Device.DeviceID
-		r = Sync(c, u, t, d)
+		r = Resync(c, u, t, d)
+		break
+	case "checkSync":
+		i := request.SessionString("pendingSyncDoc")
+		if i == "" {
+			i = u
+		}
+		a := accountName(request.SessionString("pendingAccountName"))
+		r = CheckSync(u, i, a, request.Session.Attributes["pendingResync"] == true)
+		break
+	case "switchAccount":
+		a := accountName(request.Body.Intent.Slots["accountName"].Value)
+		r = SwitchAccount(a, u, s, p)
+		break
+	case "whichAccount":
+		r = WhichAccount(u, s, p)
+		break
+	case "unlink":
+		r = respond.Questioningly("Are you sure you want to unlink this device from your Reborne account?  You'll need to sync again before you can scan.", "unlink", s, p)
+		break
+	case "AMAZON.YesIntent":
+		r = Confirm(request, u, s, p)
+		break
+	case "AMAZON.NoIntent":
+		r = Decline(request, u, s, p)
 		break
 	case "createJob":
-		j := request.Body.Intent.Slots["jobName"].Value
+		js := request.Slot("jobName")
+		if js.ConfirmationStatus == voice_request.ConfirmationDenied {
+			r = respond.Openly("Okay.  What would you like to call the job?", false, s, p)
+			break
+		}
+		j := js.Value()
 		if j == "" {
 			j = p
 		}
 		r = CreateJob(j, u, s)
 		break
 	case "scan":
-		j := request.Body.Intent.Slots["jobName"].Value
+		js := request.Slot("jobName")
+		j := js.Value()
 		if j == "" {
 			j = p
 		}
@@ -59,12 +202,186 @@ func DispatchIntents(request alexa.Request) (alexa.Response, error) {
 		r = Scan(j, u, s)
 		break
 	case "emailJob":
+		js := request.Slot("jobName")
+		t := request.Context.System.APIAccessToken
+		j := js.Value()
+		if j == "" {
+			j = p
+		}
+		at, err := deliveryTime(request)
+		if err != nil {
+			r = errors.AnalyzeError(err, s, p)
+			break
+		}
+		if c := request.Slot("contact").Value(); c != "" {
+			r = AskDeliver(t, j, "email", c, request.Slot("format").Value(), at, u, s, p)
+			break
+		}
+		r = Deliver(t, j, "email", "", request.Slot("format").Value(), at, u, s)
+		break
+	case "deliverJob":
+		js := request.Slot("jobName")
 		t := request.Context.System.APIAccessToken
-		j := request.Body.Intent.Slots["jobName"].Value
+		j := js.Value()
+		if j == "" {
+			j = p
+		}
+		m := request.Slot("method").Value()
+		at, err := deliveryTime(request)
+		if err != nil {
+			r = errors.AnalyzeError(err, s, p)
+			break
+		}
+		if c := request.Slot("contact").Value(); c != "" {
+			r = AskDeliver(t, j, m, c, request.Slot("format").Value(), at, u, s, p)
+			break
+		}
+		if m == "" {
+			r = respond.Openly("How should I send it?  I can deliver by "+respond.Enumerate(delivery.Available())+".", false, s, j)
+			break
+		}
+		f := request.Slot("format").Value()
+		targets := []queue_connect.DeliveryTarget{{Method: m, Target: request.Slot("target").Value(), Format: f}}
+		if o := request.Slot("otherMethod").Value(); o != "" {
+			targets = append(targets, queue_connect.DeliveryTarget{Method: o, Target: request.Slot("otherTarget").Value(), Format: f})
+		}
+		r = DeliverToAll(t, j, targets, at, u, s)
+		break
+	case "saveJob":
+		js := request.Slot("jobName")
+		j := js.Value()
+		if j == "" {
+			j = p
+		}
+		r = Deliver("", j, "folder", request.Slot("folder").Value(), request.Slot("format").Value(), time.Time{}, u, s)
+		break
+	case "addContact":
+		c := delivery.Contact{
+			Name:          request.Slot("contact").Value(),
+			Email:         delivery.SpokenEmail(request.Slot("email").Value()),
+			Phone:         delivery.SpokenPhone(request.Slot("phone").Value()),
+			DefaultMethod: request.Slot("method").Value(),
+		}
+		r = AddContact(c, u, s, p)
+		break
+	case "addWebhook":
+		d := delivery.Destination{Method: "webhook", Name: request.Slot("target").Value(), Address: delivery.SpokenUrl(request.Slot("address").Value())}
+		r = AddDestination(d, u, s, p)
+		break
+	case "addFolder":
+		d := delivery.Destination{
+			Method:  "folder",
+			Name:    request.Slot("target").Value(),
+			Address: delivery.SpokenFolder(request.Slot("folder").Value()),
+			Fields:  map[string]string{delivery.ProviderField: request.Slot("provider").Value()},
+		}
+		r = AddDestination(d, u, s, p)
+		break
+	case "addPrinter":
+		d := delivery.Destination{Method: "print", Name: request.Slot("target").Value(), Address: delivery.SpokenPrinterId(request.Slot("address").Value())}
+		r = AddDestination(d, u, s, p)
+		break
+	case "addFax":
+		d := delivery.Destination{Method: "fax", Name: request.Slot("target").Value(), Address: delivery.SpokenPhone(request.Slot("phone").Value())}
+		r = AddDestination(d, u, s, p)
+		break
+	case "removeDestination":
+		r = RemoveDestination(request.Slot("method").Value(), request.Slot("target").Value(), u, s, p)
+		break
+	case "listContacts":
+		r = ListContacts(u, s, p)
+		break
+	case "removeContact":
+		r = RemoveContact(request.Slot("contact").Value(), u, s, p)
+		break
+	case "renameJob":
+		js := request.Slot("jobName")
+		r = RenameJob(js.Value(), request.Slot("newJobName").Value(), u, s, p)
+		break
+	case "deleteJob":
+		js := request.Slot("jobName")
+		r = AskDeleteJob(js.Value(), u, s, p)
+		break
+	case "mergeJobs":
+		js := request.Slot("jobName")
+		ts := request.Slot("targetJobName")
+		r = MergeJobs(js.Value(), ts.Value(), u, s, p)
+		break
+	case "deliveryStatus":
+		r = DeliveryStatus(u, s, p)
+		break
+	case "retryDelivery":
+		r = RetryDelivery(u, s, p)
+		break
+	case "listScheduledDeliveries":
+		r = ListScheduledDeliveries(request.Context.System.APIAccessToken, request.Context.System.Device.DeviceID, u, s, p)
+		break
+	case "cancelScheduledDelivery":
+		js := request.Slot("jobName")
+		r = CancelScheduledDelivery(request.Context.System.APIAccessToken, request.Context.System.Device.DeviceID, js.Value(), u, s, p)
+		break
+	case "createDigest":
+		js := request.Slot("jobName")
+		m := request.Slot("method").Value()
+		if m == "" {
+			m = "email"
+		}
+		r = CreateDigest(request, request.Slot("frequency").Value(), js.Value(), m, request.Slot("target").Value(), request.Slot("format").Value(), u, s, p)
+		break
+	case "removeDigest":
+		js := request.Slot("jobName")
+		r = RemoveDigest(request.Slot("frequency").Value(), js.Value(), u, s, p)
+		break
+	case "cancelCommand":
+		r = CancelCommand(u, s, p)
+		break
+	case "setJobTimeout":
+		d, err := voice_request.ParseDuration(request.Slot("duration").Value())
+		if err != nil {
+			r = respond.Openly("I didn't catch how long to keep your jobs open.  Try saying something like keep my jobs open for thirty minutes.", false, s, p)
+			break
+		}
+		r = SetJobTimeout(d, u, s, p)
+		break
+	case "setDeliveryFormat":
+		r = SetDeliveryFormat(request.Slot("format").Value(), u, s, p)
+		break
+	case "setPdfPassword":
+		r = SetPdfPassword(true, u, s, p)
+		break
+	case "removePdfPassword":
+		r = SetPdfPassword(false, u, s, p)
+		break
+	case "setAttachmentLimit":
+		mb, err := strconv.Atoi(request.Slot("megabytes").Value())
+		if err != nil {
+			r = respond.Openly("I didn't catch how big attachments can be.  Try saying something like keep attachments under ten megabytes.", false, s, p)
+			break
+		}
+		r = SetAttachmentLimit(mb, u, s, p)
+		break
+	case "previousJob":
+		r = PreviousJob(u, s, p)
+		break
+	case "undoScan":
+		r = UndoScan(u, s, p)
+		break
+	case "listJobs":
+		r = ListJobs(0, u, s, p)
+		break
+	case "AMAZON.MoreIntent":
+		r = ListJobs(request.SessionInt("jobListOffset"), u, s, p)
+		break
+	case "jobStatus":
+		js := request.Slot("jobName")
+		j := js.Value()
 		if j == "" {
 			j = p
 		}
-		r = Deliver(t, j, u, s)
+		r = JobStatus(request.Context.System.APIAccessToken, request.Context.System.Device.DeviceID, j, u, s)
+		break
+	case "lastScanStatus":
+		r = LastScanStatus(u, s, p)
 		break
 	case "scanAndEmail":
 		t := request.Context.System.APIAccessToken
@@ -79,10 +396,248 @@ func DispatchIntents(request alexa.Request) (alexa.Response, error) {
 		r = respond.Welcome()
 		break
 	}
+	// remember what was being asked for, so confirming the job name can finish it; a yes or no that replayed
+	// an intent has already had that intent recorded, and replaying the yes itself would loop forever
+	switch request.Body.Intent.Name {
+	case "AMAZON.YesIntent", "AMAZON.NoIntent":
+		return r, nil
+	}
+	if c := r.SessionAttributes["pendingConfirmation"]; c == "jobMatch" || c == "resumeJob" {
+		r.SessionAttributes["pendingIntent"] = request.Body.Intent.Name
+		r.SessionAttributes["pendingSlots"] = request.SlotValues()
+	}
 	return r, nil
 }
 
-func Sync(code string, voiceUserId string, token string, deviceId string) alexa.Response {
+func Sync(code string, accountName string, voiceUserId string, token string, deviceId string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	defer queue_connect.CloseConnection()
+	addr, err := cloud_resources.GetDeviceAddress(token, deviceId)
+	if err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	syncDocId, err := queue_connect.SyncAccounts(code, voiceUserId, addr)
+	if err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	return awaitSync(voiceUserId, syncDocId, accountName, false)
+}
+
+func Resync(code string, voiceUserId string, token string, deviceId string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	defer queue_connect.CloseConnection()
+	addr, err := cloud_resources.GetDeviceAddress(token, deviceId)
+	if err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	syncDocId, err := queue_connect.SyncAccounts(code, voiceUserId, addr)
+	if err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	return awaitSync(voiceUserId, syncDocId, "", true)
+}
+
+func CheckSync(voiceUserId string, syncDocId string, accountName string, resync bool) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	defer queue_connect.CloseConnection()
+	return awaitSync(voiceUserId, syncDocId, accountName, resync)
+}
+
+func Unlink(voiceUserId string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	defer queue_connect.CloseConnection()
+	remaining, err := queue_connect.Unlink(voiceUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	if remaining != nil {
+		return respond.Openly("Okay, I've unlinked that account.  You're now using your "+remaining.ActiveAccount+" account.", false, remaining.SyncUserId, "")
+	}
+	return respond.Openly("This device is no longer linked to a Reborne account.  You can sync it again whenever you're ready.", false, "", "")
+}
+
+func SwitchAccount(accountName, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, j, err := queue_connect.SwitchAccount(voiceUserId, accountName)
+	if err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	return respond.Openly("Okay, you're now using your "+accountName+" account.", false, u, j)
+}
+
+func WhichAccount(voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	active, names, err := queue_connect.GetLinkedAccounts(voiceUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	message := "You're using your " + active + " account."
+	if len(names) > 1 {
+		message += "  Your linked accounts are " + respond.Enumerate(names) + "."
+	}
+	return respond.Openly(message, false, possibleSyncUserId, jobName)
+}
+
+func Confirm(request voice_request.Request, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	switch request.SessionString("pendingConfirmation") {
+	case "unlink":
+		return Unlink(voiceUserId)
+	case "jobMatch":
+		r, _ := DispatchIntents(replayWithMatchedJob(request))
+		return r
+	case "resumeJob":
+		// intents that work on the current job ignore the job slot, so the cursor has to be fresh before replaying
+		if err := resumeJob(request.SessionString("matchedJob"), voiceUserId, possibleSyncUserId); err != nil {
+			return errors.AnalyzeError(err, possibleSyncUserId, "")
+		}
+		r, _ := DispatchIntents(replayWithMatchedJob(request))
+		return r
+	case "deleteJob":
+		return DeleteJob(request.SessionString("pendingJob"), voiceUserId, possibleSyncUserId)
+	case "deliver":
+		at, _ := time.Parse(time.RFC3339, request.SessionString("pendingAt"))
+		return Deliver(request.Context.System.APIAccessToken, request.SessionString("pendingJob"), request.SessionString("pendingMethod"), request.SessionString("pendingTarget"), request.SessionString("pendingFormat"), at, voiceUserId, possibleSyncUserId)
+	default:
+		return respond.Welcome()
+	}
+}
+
+func resumeJob(jobName, voiceUserId, possibleSyncUserId string) error {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return err
+	}
+	defer queue_connect.CloseConnection()
+	_, err := queue_connect.ResumeJob(jobName, voiceUserId, possibleSyncUserId)
+	return err
+}
+
+// replayWithMatchedJob rebuilds the intent that asked which job was meant, with the confirmed job in place of the spoken one
+func replayWithMatchedJob(request voice_request.Request) voice_request.Request {
+	spoken := request.SessionString("spokenJob")
+	matched := request.SessionString("matchedJob")
+	replay := request
+	replay.Body.Intent = voice_request.Intent{Name: request.SessionString("pendingIntent"), Slots: map[string]voice_request.Slot{}}
+	// the confirmation has been answered, so the replayed intent mustn't see it as still pending
+	replay.Session.Attributes = make(map[string]interface{}, len(request.Session.Attributes))
+	for k, v := range request.Session.Attributes {
+		replay.Session.Attributes[k] = v
+	}
+	delete(replay.Session.Attributes, "pendingConfirmation")
+	delete(replay.Session.Attributes, "pendingIntent")
+	delete(replay.Session.Attributes, "pendingSlots")
+	slots, _ := request.Session.Attributes["pendingSlots"].(map[string]interface{})
+	for name, v := range slots {
+		value, _ := v.(string)
+		replay.Body.Intent.Slots[name] = voice_request.Slot{Name: name, Value: value}
+	}
+	// the job could have come from any job slot, but jobName is the usual one
+	if job_names.Canonicalize(replay.Body.Intent.Slots["jobName"].Value) == spoken {
+		replay.Body.Intent.Slots["jobName"] = voice_request.Slot{Name: "jobName", Value: matched}
+		return replay
+	}
+	for name, slot := range replay.Body.Intent.Slots {
+		if job_names.Canonicalize(slot.Value) == spoken {
+			replay.Body.Intent.Slots[name] = voice_request.Slot{Name: name, Value: matched}
+			return replay
+		}
+	}
+	replay.Body.Intent.Slots["jobName"] = voice_request.Slot{Name: "jobName", Value: matched}
+	return replay
+}
+
+func Decline(request voice_request.Request, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	switch request.SessionString("pendingConfirmation") {
+	case "jobMatch":
+		i := request.SessionString("pendingIntent")
+		if i != "createJob" && i != "scan" {
+			return respond.Openly("Okay.  Which job did you mean?", false, possibleSyncUserId, jobName)
+		}
+		return UseSpokenJob(request.SessionString("spokenJob"), i == "scan", voiceUserId, possibleSyncUserId)
+	case "resumeJob":
+		return respond.Openly("Okay.  Which job would you like to use?  You can create a new job, or tell me to scan a page to an existing one.", false, possibleSyncUserId, "")
+	default:
+		return respond.Openly("Okay, I'll leave things as they are.", false, possibleSyncUserId, jobName)
+	}
+}
+
+func UseSpokenJob(jobName string, scan bool, voiceUserId, possibleSyncUserId string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, "")
+	}
+	defer queue_connect.CloseConnection()
+	u, j, err := queue_connect.SetCursorAsSpoken(jobName, voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, j)
+	}
+	if !scan {
+		return respond.Positively("create the job "+j, false, u, j)
+	}
+	// the cursor now points at the new job, so scan into it without matching again
+	u, j, err = queue_connect.SendScanCommand("", voiceUserId, u)
+	if err != nil {
+		return errors.AnalyzeError(err, u, j)
+	}
+	return respond.Positively("scan a page", false, u, j)
+}
+
+func awaitSync(voiceUserId string, syncDocId string, accountName string, resync bool) alexa.Response {
+	syncUserId, docAccountName, accepted, err := queue_connect.AwaitSyncAcceptance(syncDocId, syncAcceptanceWait)
+	if err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	// the spoken name wins; the dashboard's name only fills in when nothing was said
+	if accountName == "" {
+		accountName = docAccountName
+	}
+	if !accepted {
+		r := respond.Openly("Your sync request is still waiting to be accepted on the Reborne dashboard.  Once you've accepted it there, ask me whether you're linked.", false, "", "")
+		r.SessionAttributes["pendingSyncDoc"] = syncDocId
+		r.SessionAttributes["pendingResync"] = resync
+		r.SessionAttributes["pendingAccountName"] = accountName
+		return r
+	}
+	if resync {
+		if err = queue_connect.CompleteResync(voiceUserId, syncUserId); err != nil {
+			return errors.AnalyzeError(err, "", "")
+		}
+		return respond.Openly("You're now linked to your Reborne account.", false, syncUserId, "")
+	}
+	savedName, err := queue_connect.LinkAccount(voiceUserId, accountName, syncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	switch {
+	case accountName != "":
+		return respond.Openly("You're now linked to the account for "+accountName+".", false, syncUserId, "")
+	case savedName == cloud_resources.DefaultAccountName:
+		return respond.Openly("You're now linked to your Reborne account.", false, syncUserId, "")
+	}
+	return respond.Openly("You're now linked to another Reborne account.  I've called it "+savedName+", so you can switch to it by name.", false, syncUserId, "")
+}
+
+func StartSync(accountName string, voiceUserId string, token string, deviceId string) alexa.Response {
 	key := key_access.GetKey()
 	if err := queue_connect.InitConnection("reborne", key); err != nil {
 		return errors.AnalyzeError(err, "", "")
@@ -92,11 +647,24 @@ func Sync(code string, voiceUserId string, token string, deviceId string) alexa.
 	if err != nil {
 		return errors.AnalyzeError(err, "", "")
 	}
-	err = queue_connect.SyncAccounts(code, voiceUserId, addr)
+	code, err := queue_connect.StartReverseSync(voiceUserId, addr)
 	if err != nil {
 		return errors.AnalyzeError(err, "", "")
 	}
-	return respond.Positively("sync your account", false, "", "")
+	spoken := strings.Join(strings.Split(code, ""), " ")
+	r := respond.Visibly("Your sync code is "+spoken+".  Enter it on the Reborne dashboard within a few minutes to link this device to your account.  I've also put the code in the Alexa app.", "Reborne sync code", code, false, "", "")
+	r.SessionAttributes["pendingSyncDoc"] = voiceUserId
+	r.SessionAttributes["pendingAccountName"] = accountName
+	return r
+}
+
+func unrecognizedCode() alexa.Response {
+	return respond.Openly("I didn't catch that code.  Please say the code shown on the Reborne dashboard again.", false, "", "")
+}
+
+// accountName is empty when no name was spoken, so the dashboard's name or a default can be used instead
+func accountName(spoken string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(spoken)), " account")
 }
 
 func Scan(jobName, voiceUserId, possibleSyncUserId string) alexa.Response {
@@ -125,34 +693,633 @@ func CreateJob(jobName, voiceUserId, possibleSyncUserId string) alexa.Response {
 	return respond.Positively("create a job", false, u, j)
 }
 
-func Deliver(token, jobName, voiceUserId, possibleSyncUserId string) alexa.Response {
+func Deliver(token, jobName, method, target, format string, at time.Time, voiceUserId, possibleSyncUserId string) alexa.Response {
+	return DeliverToAll(token, jobName, []queue_connect.DeliveryTarget{{Method: method, Target: target, Format: format}}, at, voiceUserId, possibleSyncUserId)
+}
+
+// DeliverToAll sends straight away when at is zero, otherwise schedules the delivery for then
+func DeliverToAll(token, jobName string, targets []queue_connect.DeliveryTarget, at time.Time, voiceUserId, possibleSyncUserId string) alexa.Response {
 	key := key_access.GetKey()
 	if err := queue_connect.InitConnection("reborne", key); err != nil {
 		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
 	}
 	defer queue_connect.CloseConnection()
-	email, err := cloud_resources.GetUserEmail(token, voiceUserId)
+	var u, j string
+	var err error
+	if at.IsZero() {
+		u, j, err = queue_connect.SendMultiDeliveryCommand(jobName, voiceUserId, possibleSyncUserId, targets, token)
+	} else {
+		u, j, err = queue_connect.ScheduleDelivery(jobName, voiceUserId, possibleSyncUserId, targets, token, at)
+	}
 	if err != nil {
+		return errors.AnalyzeError(err, u, j)
+	}
+	actions := make([]string, len(targets))
+	for i, t := range targets {
+		actions[i] = deliveryAction(t.Method, t.Target, j)
+	}
+	if at.IsZero() {
+		return respond.Positively(respond.Enumerate(actions), false, u, j)
+	}
+	return respond.Positively(respond.Enumerate(actions)+" at "+speakTime(at), false, u, j)
+}
+
+func ListScheduledDeliveries(token, deviceId, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
 		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
 	}
-	u, j, err := queue_connect.SendDeliveryCommand(jobName, voiceUserId, possibleSyncUserId, "email", email)
+	defer queue_connect.CloseConnection()
+	u, scheduled, err := queue_connect.ListScheduledDeliveries(voiceUserId, possibleSyncUserId)
 	if err != nil {
-		return errors.AnalyzeError(err, u, j)
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	if len(scheduled) == 0 {
+		return respond.Openly("You don't have any scheduled deliveries.", false, u, jobName)
+	}
+	loc, err := cloud_resources.GetDeviceTimeZone(token, deviceId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	items := make([]string, len(scheduled))
+	for i, d := range scheduled {
+		items[i] = "the job " + d.JobName + " by " + respond.Enumerate(d.Methods) + " at " + speakTime(d.Scheduled.In(loc))
 	}
-	return respond.Positively("email the job "+jobName, false, u, j)
+	return respond.Openly("You have scheduled deliveries of "+respond.Enumerate(items)+".", false, u, jobName)
 }
 
-func QuickScanAndSend(token, voiceUserId, possibleSyncUserId string) alexa.Response {
+func CancelScheduledDelivery(token, deviceId, deliveryJobName, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
 	key := key_access.GetKey()
 	if err := queue_connect.InitConnection("reborne", key); err != nil {
-		return errors.AnalyzeError(err, "", "")
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
 	}
 	defer queue_connect.CloseConnection()
-	email, err := cloud_resources.GetUserEmail(token, voiceUserId)
+	u, d, err := queue_connect.CancelScheduledDelivery(deliveryJobName, voiceUserId, possibleSyncUserId)
 	if err != nil {
-		return errors.AnalyzeError(err, "", "")
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	at := d.Scheduled
+	if loc, err := cloud_resources.GetDeviceTimeZone(token, deviceId); err == nil {
+		at = at.In(loc)
+	}
+	return respond.Openly("Okay, I've cancelled the "+respond.Enumerate(d.Methods)+" delivery of the job "+d.JobName+" that was scheduled for "+speakTime(at)+".", false, u, jobName)
+}
+
+func CreateDigest(request voice_request.Request, frequency, digestJobName, method, target, format, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	token := request.Context.System.APIAccessToken
+	loc, err := cloud_resources.GetDeviceTimeZone(token, request.Context.System.Device.DeviceID)
+	if err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
 	}
-	u, err := queue_connect.QuickScanAndDeliver(voiceUserId, possibleSyncUserId, "email", email)
+	first, err := voice_request.ParseDateTime(request.Slot("date").Value(), request.Slot("time").Value(), loc, time.Now())
+	if _, passed := err.(voice_request.PastTimeError); passed {
+		return errors.AnalyzeError(errors.DeliveryTimePassedError{Context: "CreateDigest", Log: err.Error()}, possibleSyncUserId, jobName)
+	}
+	if err != nil {
+		return errors.AnalyzeError(errors.InvalidInputError{ContextualError: errors.ContextualError{Context: "CreateDigest", Log: err.Error()}, ErroneousInput: "digest time"}, possibleSyncUserId, jobName)
+	}
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, d, err := queue_connect.CreateDigestRule(voiceUserId, possibleSyncUserId, frequency, digestJobName, method, target, format, token, first)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	return respond.Positively(describeDigest(d)+", starting "+speakTime(d.NextRun), false, u, jobName)
+}
+
+func RemoveDigest(frequency, digestJobName, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, removed, err := queue_connect.RemoveDigestRules(voiceUserId, possibleSyncUserId, frequency, digestJobName)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	if len(removed) == 1 {
+		return respond.Openly("Okay, I'll stop sending your "+removed[0].Frequency+" digest.", false, u, jobName)
+	}
+	return respond.Openly(fmt.Sprintf("Okay, I've removed %d digests.", len(removed)), false, u, jobName)
+}
+
+func describeDigest(d queue_connect.DigestRule) string {
+	jobs := "your updated jobs"
+	if d.JobName != "" {
+		jobs = "the job " + d.JobName
+	}
+	return "send " + jobs + " by " + d.Method + " " + d.Frequency
+}
+
+// deliveryTime reads when a delivery was asked for, in the device's time zone; zero means now
+func deliveryTime(request voice_request.Request) (time.Time, error) {
+	d, t := request.Slot("date").Value(), request.Slot("time").Value()
+	// no time at all, or just "now", sends it straight away
+	if (d == "" || d == "PRESENT_REF") && t == "" {
+		return time.Time{}, nil
+	}
+	loc, err := cloud_resources.GetDeviceTimeZone(request.Context.System.APIAccessToken, request.Context.System.Device.DeviceID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	at, err := voice_request.ParseDateTime(d, t, loc, time.Now())
+	if _, passed := err.(voice_request.PastTimeError); passed {
+		return time.Time{}, errors.DeliveryTimePassedError{Context: "deliveryTime", Log: err.Error()}
+	}
+	if err != nil {
+		return time.Time{}, errors.InvalidInputError{ContextualError: errors.ContextualError{Context: "deliveryTime", Log: err.Error()}, ErroneousInput: "delivery time"}
+	}
+	return at, nil
+}
+
+func speakTime(t time.Time) string {
+	return t.Format("3:04 PM on Monday, January 2")
+}
+
+func DeliveryStatus(voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, d, err := queue_connect.GetLastDeliveryStatus(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	var parts []string
+	for _, state := range []string{queue_connect.CommandCompleted, queue_connect.CommandPending, queue_connect.CommandScheduled, queue_connect.CommandFailed, queue_connect.CommandCancelled} {
+		if n := d.Count(state); n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, state))
+		}
+	}
+	m := fmt.Sprintf("Your last delivery, of %s, went to %s: %s.", deliveredJobs(d.JobName, d.Jobs), destinationCount(len(d.Destinations)), respond.Enumerate(parts))
+	if d.Count(queue_connect.CommandFailed) > 0 {
+		m += "  You can ask me to retry the failed ones."
+	}
+	return respond.Openly(m, false, u, jobName)
+}
+
+func RetryDelivery(voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, d, err := queue_connect.RetryFailedDeliveries(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	methods := make([]string, len(d.Destinations))
+	for i, dest := range d.Destinations {
+		methods[i] = dest.Method
+	}
+	return respond.Positively("retry the "+respond.Enumerate(methods)+" delivery of "+deliveredJobs(d.JobName, d.Jobs), false, u, jobName)
+}
+
+func deliveredJobs(jobName string, jobs []string) string {
+	if len(jobs) > 1 {
+		return "the jobs " + respond.Enumerate(jobs)
+	}
+	if jobName == "" && len(jobs) == 1 {
+		jobName = jobs[0]
+	}
+	if jobName == "" {
+		return "your jobs"
+	}
+	return "the job " + jobName
+}
+
+// AskDeliver confirms the address a contact resolved to before anything is sent
+func AskDeliver(token, jobName, method, contact, format string, at time.Time, voiceUserId, possibleSyncUserId, previousJobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, previousJobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, d, err := queue_connect.ResolveDelivery(voiceUserId, possibleSyncUserId, method, contact, token)
+	if err != nil {
+		return errors.AnalyzeError(err, u, previousJobName)
+	}
+	r := respond.Questioningly("Should I "+deliveryAction(d.Method, contact, jobName)+" at "+strings.Trim(d.Address, `"`)+"?", "deliver", u, previousJobName)
+	r.SessionAttributes["pendingJob"] = jobName
+	r.SessionAttributes["pendingMethod"] = d.Method
+	r.SessionAttributes["pendingTarget"] = contact
+	r.SessionAttributes["pendingFormat"] = format
+	if !at.IsZero() {
+		r.SessionAttributes["pendingAt"] = at.Format(time.RFC3339)
+	}
+	return r
+}
+
+func AddContact(c delivery.Contact, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, err := queue_connect.AddContact(voiceUserId, possibleSyncUserId, c)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	return respond.Positively("add "+c.Name+" to your contacts", false, u, jobName)
+}
+
+func RemoveContact(name, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, err := queue_connect.RemoveContact(voiceUserId, possibleSyncUserId, name)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	return respond.Positively("remove "+name+" from your contacts", false, u, jobName)
+}
+
+func AddDestination(d delivery.Destination, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, err := queue_connect.AddDestination(voiceUserId, possibleSyncUserId, d)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	return respond.Positively("add "+d.Name+" to your "+d.Method+" destinations", false, u, jobName)
+}
+
+func RemoveDestination(method, name, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, err := queue_connect.RemoveDestination(voiceUserId, possibleSyncUserId, method, name)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	return respond.Positively("remove "+name+" from your "+method+" destinations", false, u, jobName)
+}
+
+func ListContacts(voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, cs, err := queue_connect.ListContacts(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	if len(cs) == 0 {
+		return respond.Openly("You don't have any contacts yet.  You can ask me to add one.", false, u, jobName)
+	}
+	names := make([]string, len(cs))
+	for i, c := range cs {
+		names[i] = c.Name
+	}
+	return respond.Openly("Your contacts are "+respond.Enumerate(names)+".", false, u, jobName)
+}
+
+func deliveryAction(method, target, jobName string) string {
+	switch method {
+	case "email":
+		if target != "" {
+			return "email the job " + jobName + " to " + target
+		}
+		return "email the job " + jobName
+	case "folder":
+		if target == "" {
+			return "save the job " + jobName + " to your folder"
+		}
+		return "save the job " + jobName + " to your " + target + " folder"
+	case "print":
+		if target == "" {
+			return "print the job " + jobName
+		}
+		return "print the job " + jobName + " on the " + target + " printer"
+	case "fax":
+		if target == "" {
+			return "fax the job " + jobName
+		}
+		return "fax the job " + jobName + " to " + target
+	}
+	return "send the job " + jobName + " by " + method
+}
+
+func RenameJob(jobName, newJobName, voiceUserId, possibleSyncUserId, previousJobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, previousJobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, j, err := queue_connect.RenameJob(jobName, newJobName, voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, previousJobName)
+	}
+	return respond.Positively("rename the job to "+job_names.Canonicalize(newJobName), false, u, j)
+}
+
+func AskDeleteJob(jobName, voiceUserId, possibleSyncUserId, previousJobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, previousJobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, j, err := queue_connect.FindJob(jobName, voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, previousJobName)
+	}
+	r := respond.Questioningly("Are you sure you want to delete the job "+j+" and all of its pages?", "deleteJob", u, previousJobName)
+	r.SessionAttributes["pendingJob"] = j
+	return r
+}
+
+func DeleteJob(jobName, voiceUserId, possibleSyncUserId string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, "")
+	}
+	defer queue_connect.CloseConnection()
+	u, j, err := queue_connect.DeleteJob(jobName, voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, "")
+	}
+	return respond.Positively("delete the job "+jobName, false, u, j)
+}
+
+func MergeJobs(jobName, targetJobName, voiceUserId, possibleSyncUserId, previousJobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, previousJobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, j, err := queue_connect.MergeJobs(jobName, targetJobName, voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, previousJobName)
+	}
+	return respond.Positively("merge those jobs", false, u, j)
+}
+
+func CancelCommand(voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, c, err := queue_connect.CancelNewestCommand(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	if c.Kind == "delivery" && c.JobName == "" {
+		return respond.Openly("Okay, I've cancelled the "+c.Method+" digest of "+deliveredJobs(c.JobName, c.Jobs)+".", false, u, jobName)
+	}
+	if c.Kind == "delivery" {
+		return respond.Openly("Okay, I've cancelled the "+c.Method+" delivery of the job "+c.JobName+".", false, u, jobName)
+	}
+	return respond.Openly("Okay, I've cancelled the scan into the job "+c.JobName+".", false, u, jobName)
+}
+
+func SetJobTimeout(ttl time.Duration, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, err := queue_connect.SetCursorTtl(voiceUserId, possibleSyncUserId, ttl)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	return respond.Openly("Okay, I'll keep your jobs open for "+speakDuration(ttl)+" after each scan.", false, u, jobName)
+}
+
+func SetDeliveryFormat(choice, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, o, err := queue_connect.SetDeliveryFormat(voiceUserId, possibleSyncUserId, choice)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	return respond.Openly("Okay, I'll deliver your jobs as "+describeOptions(o)+" from now on.", false, u, jobName)
+}
+
+func SetPdfPassword(enabled bool, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, err := queue_connect.SetPdfPassword(voiceUserId, possibleSyncUserId, enabled)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	if !enabled {
+		return respond.Openly("Okay, I won't password protect your deliveries any more.", false, u, jobName)
+	}
+	return respond.Openly("Okay, protected deliveries will be locked with the PDF password on your Reborne dashboard.  Ask for a protected PDF when you deliver, or make it your default.", false, u, jobName)
+}
+
+func SetAttachmentLimit(megabytes int, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, err := queue_connect.SetAttachmentLimit(voiceUserId, possibleSyncUserId, megabytes)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	if megabytes == 0 {
+		return respond.Openly("Okay, I'll only split deliveries when the destination needs it.", false, u, jobName)
+	}
+	return respond.Openly(fmt.Sprintf("Okay, I'll split deliveries into parts of no more than %d megabytes.", megabytes), false, u, jobName)
+}
+
+func describeOptions(o delivery.Options) string {
+	images := o.Format == delivery.FormatImages
+	if !o.Zip {
+		if o.PasswordRef != "" {
+			return "a password protected PDF"
+		}
+		if images {
+			return "an image for each page"
+		}
+		return "a single PDF"
+	}
+	zip := "a zip file"
+	if o.PasswordRef != "" {
+		zip = "a password protected zip file"
+	}
+	if images {
+		return zip + " of page images"
+	}
+	return zip + " holding a single PDF"
+}
+
+func speakDuration(d time.Duration) string {
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	parts := []string{}
+	if h == 1 {
+		parts = append(parts, "1 hour")
+	} else if h > 1 {
+		parts = append(parts, fmt.Sprintf("%d hours", h))
+	}
+	if m == 1 {
+		parts = append(parts, "1 minute")
+	} else if m > 1 {
+		parts = append(parts, fmt.Sprintf("%d minutes", m))
+	}
+	return respond.Enumerate(parts)
+}
+
+func PreviousJob(voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, j, err := queue_connect.SwitchToPreviousJob(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	return respond.Openly("Okay, you're back on the job "+j+".", false, u, j)
+}
+
+func UndoScan(voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, j, err := queue_connect.UndoLastScan(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, j)
+	}
+	return respond.Positively("remove the last page from the job "+j, false, u, j)
+}
+
+func ListJobs(offset int, voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, jobs, more, err := queue_connect.ListJobs(voiceUserId, possibleSyncUserId, offset, jobListPageSize)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	if len(jobs) == 0 && offset == 0 {
+		return respond.Openly("You don't have any jobs yet.  You can create one by telling me to create a job.", false, u, jobName)
+	}
+	if len(jobs) == 0 {
+		return respond.Openly("That's all of your jobs.", false, u, jobName)
+	}
+	descriptions := make([]string, len(jobs))
+	for i, j := range jobs {
+		descriptions[i] = describeJob(j)
+	}
+	message := "Your most recent jobs are " + respond.Enumerate(descriptions) + "."
+	if offset > 0 {
+		message = "Your next jobs are " + respond.Enumerate(descriptions) + "."
+	}
+	if more {
+		message += "  Say more to hear more of your jobs."
+	}
+	r := respond.Openly(message, false, u, jobName)
+	// kept even on the last page, so asking for more after it says that's all rather than starting over
+	r.SessionAttributes["jobListOffset"] = offset + len(jobs)
+	return r
+}
+
+func JobStatus(token, deviceId, jobName, voiceUserId, possibleSyncUserId string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, status, err := queue_connect.GetJobStatus(jobName, voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	if status.Pages() == 0 {
+		return respond.Openly("I haven't scanned any pages into the job "+status.JobName+" yet.", false, u, status.JobName)
+	}
+	counts := []string{}
+	if status.Completed > 0 {
+		counts = append(counts, fmt.Sprintf("%d completed", status.Completed))
+	}
+	if status.Pending > 0 {
+		counts = append(counts, fmt.Sprintf("%d still pending", status.Pending))
+	}
+	if status.Failed > 0 {
+		counts = append(counts, fmt.Sprintf("%d failed", status.Failed))
+	}
+	last := status.LastScanned
+	if loc, err := cloud_resources.GetDeviceTimeZone(token, deviceId); err == nil {
+		last = last.In(loc)
+	}
+	message := fmt.Sprintf("The job %s has %s: %s.  It was last scanned into at %s.", status.JobName, pageCount(status.Pages()), respond.Enumerate(counts), speakTime(last))
+	return respond.Openly(message, false, u, status.JobName)
+}
+
+func LastScanStatus(voiceUserId, possibleSyncUserId, jobName string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, possibleSyncUserId, jobName)
+	}
+	defer queue_connect.CloseConnection()
+	u, j, state, err := queue_connect.GetLastScanStatus(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return errors.AnalyzeError(err, u, jobName)
+	}
+	switch state {
+	case "":
+		return respond.Openly("I haven't scanned anything for you yet.", false, u, jobName)
+	case queue_connect.CommandCompleted:
+		return respond.Openly("Your last scan, into the job "+j+", completed.", false, u, jobName)
+	case queue_connect.CommandFailed:
+		return respond.Openly("Your last scan, into the job "+j+", failed.  Check the scanner and try scanning that page again.", false, u, jobName)
+	case queue_connect.CommandCancelled:
+		return respond.Openly("Your last scan, into the job "+j+", was cancelled.", false, u, jobName)
+	default:
+		return respond.Openly("Your last scan, into the job "+j+", is still pending.", false, u, jobName)
+	}
+}
+
+func pageCount(n int) string {
+	if n == 1 {
+		return "1 page"
+	}
+	return fmt.Sprintf("%d pages", n)
+}
+
+func destinationCount(n int) string {
+	if n == 1 {
+		return "1 destination"
+	}
+	return fmt.Sprintf("%d destinations", n)
+}
+
+func describeJob(j queue_connect.Job) string {
+	return fmt.Sprintf("%s, with %s, updated %s", j.Name, pageCount(j.Pages), j.Updated.Format("January 2"))
+}
+
+func QuickScanAndSend(token, voiceUserId, possibleSyncUserId string) alexa.Response {
+	key := key_access.GetKey()
+	if err := queue_connect.InitConnection("reborne", key); err != nil {
+		return errors.AnalyzeError(err, "", "")
+	}
+	defer queue_connect.CloseConnection()
+	u, err := queue_connect.QuickScanAndDeliver(voiceUserId, possibleSyncUserId, "email", "", token)
 	if err != nil {
 		return errors.AnalyzeError(err, u, "")
 	}
@@ -0,0 +1,101 @@
+package queue_connect
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"speechLiason/errors"
+	"time"
+)
+
+type CancelledCommand struct {
+	Kind    string
+	JobName string
+	Method  string
+	// a digest covers every job here and leaves JobName empty when there's more than one
+	Jobs []string
+}
+
+// CancelNewestCommand cancels whichever of the user's scan or delivery commands was most
+// recently added and hasn't been picked up yet
+func CancelNewestCommand(voiceUserId, possibleSyncUserId string) (syncUserId string, cancelled CancelledCommand, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	var newest *firestore.DocumentSnapshot
+	var created time.Time
+	for _, kind := range []string{"scan", "delivery"} {
+		docs, err := client.Collection(kind).
+			Where("u", "==", syncUserId).
+			Where("s", "==", CommandPending).
+			OrderBy("t", firestore.Desc).
+			Limit(1).
+			Documents(ctx).GetAll()
+		if err != nil {
+			return syncUserId, cancelled, errors.SystemError{UserId: syncUserId, Context: "CancelNewestCommand", Log: fmt.Sprintf("could not retrieve pending %s commands: %s", kind, err)}
+		}
+		if len(docs) == 0 {
+			continue
+		}
+		t, _ := docs[0].DataAt("t")
+		if c, ok := t.(time.Time); ok && (newest == nil || c.After(created)) {
+			newest, created = docs[0], c
+			cancelled.Kind = kind
+		}
+	}
+	if newest == nil {
+		return syncUserId, cancelled, errors.NothingToCancelError{UserId: voiceUserId, Context: "CancelNewestCommand", Log: "no pending scan or delivery commands"}
+	}
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(newest.Ref)
+		if err != nil {
+			return err
+		}
+		// the agent may have picked it up since we looked
+		if s, _ := snap.DataAt("s"); s != CommandPending {
+			return errors.NothingToCancelError{UserId: voiceUserId, Context: "CancelNewestCommand", Log: fmt.Sprintf("%s command %s is no longer pending", cancelled.Kind, newest.Ref.ID)}
+		}
+		j, _ := snap.DataAt("j")
+		m, _ := snap.DataAt("m")
+		cancelled.JobName, _ = j.(string)
+		cancelled.Method, _ = m.(string)
+		if b, _ := snap.DataAt("b"); b != nil {
+			names, _ := b.([]interface{})
+			for _, n := range names {
+				if name, ok := n.(string); ok {
+					cancelled.Jobs = append(cancelled.Jobs, name)
+				}
+			}
+		}
+		refs := []*firestore.DocumentRef{newest.Ref}
+		// a delivery to several destinations is cancelled as a whole
+		if g, _ := snap.DataAt("g"); g != nil && g != "" {
+			siblings, err := tx.Documents(client.Collection("delivery").
+				Where("u", "==", syncUserId).
+				Where("g", "==", g).
+				Where("s", "==", CommandPending)).GetAll()
+			if err != nil {
+				return err
+			}
+			for _, sib := range siblings {
+				if sib.Ref.ID != newest.Ref.ID {
+					refs = append(refs, sib.Ref)
+				}
+			}
+		}
+		for _, ref := range refs {
+			if err = tx.Update(ref, []firestore.Update{{Path: "s", Value: CommandCancelled}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(errors.NothingToCancelError); ok {
+			return syncUserId, cancelled, err
+		}
+		return syncUserId, cancelled, errors.SystemError{UserId: syncUserId, Context: "CancelNewestCommand", Log: fmt.Sprintf("could not cancel %s command: %s", cancelled.Kind, err)}
+	}
+	return
+}
@@ -0,0 +1,42 @@
+package queue_connect
+
+import (
+	"speechLiason/delivery"
+)
+
+func AddContact(voiceUserId, possibleSyncUserId string, c delivery.Contact) (syncUserId string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	err = delivery.SaveContact(requester(voiceUserId, syncUserId, "", ""), c)
+	return
+}
+
+func RemoveContact(voiceUserId, possibleSyncUserId, name string) (syncUserId string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	err = delivery.RemoveContact(requester(voiceUserId, syncUserId, "", ""), name)
+	return
+}
+
+func ListContacts(voiceUserId, possibleSyncUserId string) (syncUserId string, contacts []delivery.Contact, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	contacts, err = delivery.ListContacts(requester(voiceUserId, syncUserId, "", ""))
+	return
+}
+
+// ResolveDelivery looks up where a delivery would go without sending it, so the address can be confirmed first
+func ResolveDelivery(voiceUserId, possibleSyncUserId, method, target, apiToken string) (syncUserId string, d delivery.Destination, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	d, _, _, err = resolveDelivery(method, target, "", apiToken, voiceUserId, syncUserId)
+	return
+}
@@ -0,0 +1,166 @@
+package queue_connect
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"speechLiason/errors"
+	"time"
+)
+
+// a delivery group is one logical delivery; each of its destinations gets its own delivery doc
+// so the agent can report, and the user retry, each one separately
+type deliveryGroupDoc struct {
+	UserId      string    `firestore:"u"`
+	VoiceUserId string    `firestore:"v"`
+	JobName     string    `firestore:"j"`
+	Created     time.Time `firestore:"t"`
+	Size        int       `firestore:"n"`
+	Scheduled   time.Time `firestore:"w"`
+	Jobs        []string  `firestore:"b"`
+}
+
+// DeliveryTarget names a method and destination, and the format choice for it; an empty format uses the user's default
+type DeliveryTarget struct {
+	Method string
+	Target string
+	Format string
+}
+
+type DestinationStatus struct {
+	Method      string
+	Destination string
+	State       string
+}
+
+type DeliveryStatus struct {
+	JobName string
+	// Jobs lists the jobs a digest delivered together
+	Jobs         []string
+	Created      time.Time
+	Destinations []DestinationStatus
+}
+
+func (s DeliveryStatus) Count(state string) int {
+	n := 0
+	for _, d := range s.Destinations {
+		if d.State == state {
+			n++
+		}
+	}
+	return n
+}
+
+// SendMultiDeliveryCommand resolves every destination before writing anything, then writes the
+// group and its delivery docs in one batch so a delivery is never left half queued
+func SendMultiDeliveryCommand(jobName, voiceUserId, possibleSyncUserId string, targets []DeliveryTarget, apiToken string) (syncUserId, sessionJobName string, err error) {
+	return queueDeliveries(jobName, voiceUserId, possibleSyncUserId, targets, apiToken, time.Time{})
+}
+
+// queueDeliveries leaves the deliveries scheduled when at is set, otherwise pending
+func queueDeliveries(jobName, voiceUserId, possibleSyncUserId string, targets []DeliveryTarget, apiToken string, at time.Time) (syncUserId, sessionJobName string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	sessionJobName, err = setJobName(jobName, voiceUserId, syncUserId)
+	if err != nil {
+		return syncUserId, "", err
+	}
+	now := time.Now()
+	state := CommandPending
+	if !at.IsZero() {
+		state = CommandScheduled
+	}
+	g := client.Collection("deliveryGroup").NewDoc()
+	docs := make([]deliverDoc, 0, len(targets))
+	for _, t := range targets {
+		dest, payload, opts, err := resolveDelivery(t.Method, t.Target, t.Format, apiToken, voiceUserId, syncUserId)
+		if err != nil {
+			return syncUserId, sessionJobName, err
+		}
+		docs = append(docs, deliverDoc{syncUserId, voiceUserId, sessionJobName, dest.Method, dest.Address, now, state, payload, g.ID, at, opts, nil})
+	}
+	b := client.Batch()
+	b.Create(g, deliveryGroupDoc{syncUserId, voiceUserId, sessionJobName, now, len(docs), at, nil})
+	for _, d := range docs {
+		b.Create(client.Collection("delivery").NewDoc(), d)
+	}
+	if _, err = b.Commit(ctx); err != nil {
+		return syncUserId, sessionJobName, errors.SystemError{JobName: sessionJobName, UserId: voiceUserId, Context: "queueDeliveries", Log: fmt.Sprintf("could not create delivery commands: %s", err)}
+	}
+	return
+}
+
+func GetLastDeliveryStatus(voiceUserId, possibleSyncUserId string) (syncUserId string, status DeliveryStatus, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	_, status, _, err = lastDeliveryGroup(syncUserId)
+	return
+}
+
+// RetryFailedDeliveries puts the failed destinations of the user's last delivery back in the queue
+func RetryFailedDeliveries(voiceUserId, possibleSyncUserId string) (syncUserId string, retried DeliveryStatus, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	g, status, refs, err := lastDeliveryGroup(syncUserId)
+	if err != nil {
+		return
+	}
+	retried.JobName, retried.Jobs, retried.Created = status.JobName, status.Jobs, status.Created
+	b := client.Batch()
+	for i, d := range status.Destinations {
+		if d.State != CommandFailed {
+			continue
+		}
+		b.Update(refs[i], []firestore.Update{{Path: "s", Value: CommandPending}, {Path: "t", Value: time.Now()}})
+		d.State = CommandPending
+		retried.Destinations = append(retried.Destinations, d)
+	}
+	if len(retried.Destinations) == 0 {
+		return syncUserId, retried, errors.NothingToRetryError{JobName: status.JobName, UserId: voiceUserId, Context: "RetryFailedDeliveries", Log: fmt.Sprintf("no failed destinations in delivery group %s", g)}
+	}
+	if _, err = b.Commit(ctx); err != nil {
+		return syncUserId, retried, errors.SystemError{JobName: status.JobName, UserId: voiceUserId, Context: "RetryFailedDeliveries", Log: fmt.Sprintf("could not retry delivery group %s: %s", g, err)}
+	}
+	return
+}
+
+func lastDeliveryGroup(syncUserId string) (groupId string, status DeliveryStatus, refs []*firestore.DocumentRef, err error) {
+	groups, err := client.Collection("deliveryGroup").
+		Where("u", "==", syncUserId).
+		OrderBy("t", firestore.Desc).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return "", status, nil, errors.SystemError{UserId: syncUserId, Context: "lastDeliveryGroup", Log: fmt.Sprintf("could not retrieve delivery groups: %s", err)}
+	}
+	if len(groups) == 0 {
+		return "", status, nil, errors.NoDeliveriesError{UserId: syncUserId, Context: "lastDeliveryGroup", Log: "no deliveries sent"}
+	}
+	var g deliveryGroupDoc
+	if err = groups[0].DataTo(&g); err != nil {
+		return "", status, nil, errors.SystemError{UserId: syncUserId, Context: "lastDeliveryGroup", Log: fmt.Sprintf("could not map delivery group doc to struct: %s", err)}
+	}
+	groupId = groups[0].Ref.ID
+	status.JobName, status.Jobs, status.Created = g.JobName, g.Jobs, g.Created
+	docs, err := client.Collection("delivery").
+		Where("u", "==", syncUserId).
+		Where("g", "==", groupId).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return groupId, status, nil, errors.SystemError{JobName: g.JobName, UserId: syncUserId, Context: "lastDeliveryGroup", Log: fmt.Sprintf("could not retrieve deliveries in group %s: %s", groupId, err)}
+	}
+	for _, doc := range docs {
+		var d deliverDoc
+		if err = doc.DataTo(&d); err != nil {
+			return groupId, status, nil, errors.SystemError{JobName: g.JobName, UserId: syncUserId, Context: "lastDeliveryGroup", Log: fmt.Sprintf("could not map delivery doc to struct: %s", err)}
+		}
+		status.Destinations = append(status.Destinations, DestinationStatus{d.Method, d.Destination, d.State})
+		refs = append(refs, doc.Ref)
+	}
+	return
+}
@@ -0,0 +1,23 @@
+package queue_connect
+
+import (
+	"speechLiason/delivery"
+)
+
+func AddDestination(voiceUserId, possibleSyncUserId string, d delivery.Destination) (syncUserId string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	err = delivery.SaveDestination(requester(voiceUserId, syncUserId, "", ""), d)
+	return
+}
+
+func RemoveDestination(voiceUserId, possibleSyncUserId, method, name string) (syncUserId string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	err = delivery.RemoveDestination(requester(voiceUserId, syncUserId, "", ""), method, name)
+	return
+}
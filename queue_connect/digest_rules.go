@@ -0,0 +1,199 @@
+package queue_connect
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"speechLiason/delivery"
+	"speechLiason/errors"
+	"time"
+)
+
+const (
+	DigestDaily   = "daily"
+	DigestWeekly  = "weekly"
+	DigestMonthly = "monthly"
+)
+
+// a digest rule redelivers jobs on a schedule; its destination is resolved when the rule is made,
+// since things like the profile email can't be looked up without the user there
+type digestRuleDoc struct {
+	UserId      string                 `firestore:"u"`
+	VoiceUserId string                 `firestore:"v"`
+	Frequency   string                 `firestore:"f"`
+	JobName     string                 `firestore:"j"`
+	Method      string                 `firestore:"m"`
+	Destination string                 `firestore:"d"`
+	Payload     map[string]interface{} `firestore:"p"`
+	Options     delivery.Options       `firestore:"o"`
+	Location    string                 `firestore:"z"`
+	NextRun     time.Time              `firestore:"w"`
+	LastRun     time.Time              `firestore:"l"`
+	// monthly rules come back to the day of the month they started on, even after a shorter month
+	DayOfMonth int `firestore:"y"`
+}
+
+type DigestRule struct {
+	Frequency string
+	JobName   string
+	Method    string
+	NextRun   time.Time
+}
+
+// CreateDigestRule adds a rule first running at first, whose zone is the one later runs keep to;
+// with no job name the digest covers every job updated since the last run
+func CreateDigestRule(voiceUserId, possibleSyncUserId, frequency, jobName, method, target, format, apiToken string, first time.Time) (syncUserId string, rule DigestRule, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	switch frequency {
+	case DigestDaily, DigestWeekly, DigestMonthly:
+	default:
+		return syncUserId, rule, errors.InvalidInputError{ContextualError: errors.ContextualError{UserId: voiceUserId, Context: "CreateDigestRule", Log: fmt.Sprintf("unsupported digest frequency %q", frequency)}, ErroneousInput: "digest frequency"}
+	}
+	if jobName != "" {
+		if jobName, _, err = resolveJobName(jobName, voiceUserId, syncUserId); err != nil {
+			return
+		}
+	}
+	dest, payload, opts, err := resolveDelivery(method, target, format, apiToken, voiceUserId, syncUserId)
+	if err != nil {
+		return
+	}
+	d := digestRuleDoc{syncUserId, voiceUserId, frequency, jobName, dest.Method, dest.Address, payload, opts, first.Location().String(), first, time.Now(), first.Day()}
+	if _, _, err = client.Collection("digestRule").Add(ctx, d); err != nil {
+		return syncUserId, rule, errors.SystemError{JobName: jobName, UserId: syncUserId, Context: "CreateDigestRule", Log: fmt.Sprintf("could not create digest rule: %s", err)}
+	}
+	return syncUserId, DigestRule{frequency, jobName, dest.Method, first}, nil
+}
+
+// RemoveDigestRules removes the user's rules matching frequency and job name, either of which may be empty to match any
+func RemoveDigestRules(voiceUserId, possibleSyncUserId, frequency, jobName string) (syncUserId string, removed []DigestRule, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	if jobName != "" {
+		if jobName, _, err = resolveJobName(jobName, voiceUserId, syncUserId); err != nil {
+			return
+		}
+	}
+	q := client.Collection("digestRule").Where("u", "==", syncUserId)
+	if frequency != "" {
+		q = q.Where("f", "==", frequency)
+	}
+	if jobName != "" {
+		q = q.Where("j", "==", jobName)
+	}
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return syncUserId, nil, errors.SystemError{JobName: jobName, UserId: syncUserId, Context: "RemoveDigestRules", Log: fmt.Sprintf("could not retrieve digest rules: %s", err)}
+	}
+	if len(docs) == 0 {
+		return syncUserId, nil, errors.DigestRuleNotFoundError{JobName: jobName, UserId: voiceUserId, Context: "RemoveDigestRules", Log: fmt.Sprintf("no %s digest rules for job %q", frequency, jobName)}
+	}
+	b := client.Batch()
+	for _, doc := range docs {
+		var d digestRuleDoc
+		if err = doc.DataTo(&d); err != nil {
+			return syncUserId, nil, errors.SystemError{JobName: jobName, UserId: syncUserId, Context: "RemoveDigestRules", Log: fmt.Sprintf("could not map digest rule doc to struct: %s", err)}
+		}
+		b.Delete(doc.Ref)
+		removed = append(removed, DigestRule{d.Frequency, d.JobName, d.Method, d.NextRun})
+	}
+	if _, err = b.Commit(ctx); err != nil {
+		return syncUserId, nil, errors.SystemError{JobName: jobName, UserId: syncUserId, Context: "RemoveDigestRules", Log: fmt.Sprintf("could not remove digest rules: %s", err)}
+	}
+	return
+}
+
+// RunDueDigests emits an ordinary delivery doc for every rule due by now, bundling the jobs it covers.
+// A rule's delivery and its move to the next run are written together, and only if nothing else ran it first.
+func RunDueDigests(now time.Time) (emitted int, err error) {
+	docs, err := client.Collection("digestRule").
+		Where("w", "<=", now).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return 0, errors.SystemError{Context: "RunDueDigests", Log: fmt.Sprintf("could not retrieve due digest rules: %s", err)}
+	}
+	for _, doc := range docs {
+		n, err := runDigest(doc, now)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		emitted += n
+	}
+	return
+}
+
+func runDigest(doc *firestore.DocumentSnapshot, now time.Time) (int, error) {
+	var d digestRuleDoc
+	if err := doc.DataTo(&d); err != nil {
+		return 0, errors.SystemError{Context: "runDigest", Log: fmt.Sprintf("could not map digest rule doc %s to struct: %s", doc.Ref.ID, err)}
+	}
+	names := []string{d.JobName}
+	if d.JobName == "" {
+		all, err := jobs.ListJobs(d.UserId)
+		if err != nil {
+			return 0, err
+		}
+		names = names[:0]
+		for _, j := range all {
+			if j.Updated.After(d.LastRun) {
+				names = append(names, j.Name)
+			}
+		}
+	}
+	b := client.Batch()
+	emitted := 0
+	if len(names) > 0 {
+		var jobName string
+		if len(names) == 1 {
+			jobName = names[0]
+		}
+		g := client.Collection("deliveryGroup").NewDoc()
+		b.Create(g, deliveryGroupDoc{d.UserId, d.VoiceUserId, jobName, now, 1, time.Time{}, names})
+		b.Create(client.Collection("delivery").NewDoc(), deliverDoc{d.UserId, d.VoiceUserId, jobName, d.Method, d.Destination, now, CommandPending, d.Payload, g.ID, time.Time{}, d.Options, names})
+		emitted = 1
+	}
+	b.Update(doc.Ref, []firestore.Update{{Path: "w", Value: nextDigestRun(d, now)}, {Path: "l", Value: now}}, firestore.LastUpdateTime(doc.UpdateTime))
+	if _, err := b.Commit(ctx); err != nil {
+		return 0, errors.SystemError{UserId: d.UserId, Context: "runDigest", Log: fmt.Sprintf("could not run digest rule %s: %s", doc.Ref.ID, err)}
+	}
+	return emitted, nil
+}
+
+// nextDigestRun steps the rule forward in its own zone, so it keeps its local time across daylight saving
+func nextDigestRun(d digestRuleDoc, now time.Time) time.Time {
+	loc, err := time.LoadLocation(d.Location)
+	if err != nil {
+		loc = time.UTC
+	}
+	next := d.NextRun.In(loc)
+	day := d.DayOfMonth
+	if day == 0 {
+		day = next.Day()
+	}
+	for !next.After(now) {
+		switch d.Frequency {
+		case DigestDaily:
+			next = next.AddDate(0, 0, 1)
+		case DigestWeekly:
+			next = next.AddDate(0, 0, 7)
+		default:
+			// AddDate would carry the 31st of a short month into the next one, and then stay on that day
+			next = dayOfMonth(next.Year(), next.Month()+1, day, next)
+		}
+	}
+	return next
+}
+
+// dayOfMonth is day in the given month at clock's time of day, or the month's last day if it's shorter
+func dayOfMonth(year int, month time.Month, day int, clock time.Time) time.Time {
+	// day zero of the following month is the last day of this one
+	if last := time.Date(year, month+1, 0, 0, 0, 0, 0, clock.Location()).Day(); day > last {
+		day = last
+	}
+	return time.Date(year, month, day, clock.Hour(), clock.Minute(), clock.Second(), 0, clock.Location())
+}
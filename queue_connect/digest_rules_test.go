@@ -0,0 +1,44 @@
+package queue_connect
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextDigestRun(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, ny)
+	}
+	cases := []struct {
+		name       string
+		frequency  string
+		nextRun    time.Time
+		dayOfMonth int
+		now        time.Time
+		want       time.Time
+	}{
+		{"daily", DigestDaily, at(2026, 10, 19, 9), 19, at(2026, 10, 19, 9), at(2026, 10, 20, 9)},
+		{"daily catches up after missed runs", DigestDaily, at(2026, 10, 15, 9), 15, at(2026, 10, 19, 10), at(2026, 10, 20, 9)},
+		{"weekly", DigestWeekly, at(2026, 10, 19, 9), 19, at(2026, 10, 19, 9), at(2026, 10, 26, 9)},
+		{"daily keeps local time over daylight saving", DigestDaily, at(2026, 10, 31, 9), 31, at(2026, 10, 31, 9), at(2026, 11, 1, 9)},
+		{"weekly keeps local time over daylight saving", DigestWeekly, at(2026, 3, 5, 9), 5, at(2026, 3, 5, 9), at(2026, 3, 12, 9)},
+		{"monthly", DigestMonthly, at(2026, 10, 19, 9), 19, at(2026, 10, 19, 9), at(2026, 11, 19, 9)},
+		{"monthly clamps to a short month", DigestMonthly, at(2026, 1, 31, 9), 31, at(2026, 1, 31, 9), at(2026, 2, 28, 9)},
+		{"monthly returns to its day after a short month", DigestMonthly, at(2026, 2, 28, 9), 31, at(2026, 2, 28, 9), at(2026, 3, 31, 9)},
+		{"monthly clamps to a thirty day month", DigestMonthly, at(2026, 3, 31, 9), 31, at(2026, 3, 31, 9), at(2026, 4, 30, 9)},
+		{"monthly in a leap year", DigestMonthly, at(2028, 1, 30, 9), 30, at(2028, 1, 30, 9), at(2028, 2, 29, 9)},
+		{"monthly across the year end", DigestMonthly, at(2026, 12, 31, 9), 31, at(2026, 12, 31, 9), at(2027, 1, 31, 9)},
+		{"monthly without a stored day uses the next run's", DigestMonthly, at(2026, 10, 19, 9), 0, at(2026, 10, 19, 9), at(2026, 11, 19, 9)},
+		{"not yet due stays put", DigestWeekly, at(2026, 10, 26, 9), 26, at(2026, 10, 19, 9), at(2026, 10, 26, 9)},
+	}
+	for _, c := range cases {
+		d := digestRuleDoc{Frequency: c.frequency, Location: ny.String(), NextRun: c.nextRun.UTC(), DayOfMonth: c.dayOfMonth}
+		if got := nextDigestRun(d, c.now); !got.Equal(c.want) {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
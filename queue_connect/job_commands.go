@@ -0,0 +1,200 @@
+package queue_connect
+
+import (
+	"fmt"
+	"speechLiason/errors"
+	"speechLiason/job_names"
+	"time"
+)
+
+// job commands are picked up by the back end alongside scan and delivery docs
+const (
+	JobRename  = "rename"
+	JobDelete  = "delete"
+	JobMerge   = "merge"
+	PageRemove = "removePage"
+)
+
+type jobCommandDoc struct {
+	UserId      string    `firestore:"u"`
+	VoiceUserId string    `firestore:"v"`
+	Command     string    `firestore:"c"`
+	JobName     string    `firestore:"j"`
+	Target      string    `firestore:"n"`
+	Created     time.Time `firestore:"t"`
+	ScanId      string    `firestore:"s"`
+}
+
+type lastScanDoc struct {
+	ScanId  string    `firestore:"s"`
+	JobName string    `firestore:"j"`
+	Created time.Time `firestore:"t"`
+}
+
+func FindJob(jobName, voiceUserId, possibleSyncUserId string) (syncUserId, foundJobName string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	foundJobName, err = findJob(jobName, voiceUserId, syncUserId)
+	return
+}
+
+func RenameJob(jobName, newJobName, voiceUserId, possibleSyncUserId string) (syncUserId, activeJobName string, err error) {
+	syncUserId, jobName, err = FindJob(jobName, voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	// the new name is the user's choice, so it's only checked for an exact clash rather than fuzzy matched
+	target := job_names.Canonicalize(newJobName)
+	if target == "" {
+		return syncUserId, "", errors.MissingJobNameError{JobName: newJobName, UserId: voiceUserId, Context: "RenameJob", Log: "new job name was empty once canonicalized"}
+	}
+	all, err := jobs.ListJobs(syncUserId)
+	if err != nil {
+		return
+	}
+	for _, j := range all {
+		if job_names.Canonicalize(j.Name) == target {
+			return syncUserId, "", errors.JobExistsError{JobName: target, UserId: voiceUserId, Context: "RenameJob", Log: fmt.Sprintf("can't rename %s to existing job %s", jobName, target)}
+		}
+	}
+	if err = sendJobCommand(JobRename, jobName, target, voiceUserId, syncUserId); err != nil {
+		return
+	}
+	activeJobName, err = moveCursor(jobName, target, voiceUserId, syncUserId)
+	return
+}
+
+func DeleteJob(jobName, voiceUserId, possibleSyncUserId string) (syncUserId, activeJobName string, err error) {
+	syncUserId, jobName, err = FindJob(jobName, voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	if err = sendJobCommand(JobDelete, jobName, "", voiceUserId, syncUserId); err != nil {
+		return
+	}
+	activeJobName, err = moveCursor(jobName, "", voiceUserId, syncUserId)
+	return
+}
+
+func MergeJobs(jobName, targetJobName, voiceUserId, possibleSyncUserId string) (syncUserId, activeJobName string, err error) {
+	syncUserId, jobName, err = FindJob(jobName, voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	target, err := findJob(targetJobName, voiceUserId, syncUserId)
+	if err != nil {
+		return
+	}
+	if target == jobName {
+		return syncUserId, "", errors.InvalidInputError{ContextualError: errors.ContextualError{JobName: jobName, UserId: voiceUserId, Context: "MergeJobs", Log: "can't merge a job into itself"}, ErroneousInput: "job name"}
+	}
+	if err = sendJobCommand(JobMerge, jobName, target, voiceUserId, syncUserId); err != nil {
+		return
+	}
+	activeJobName, err = moveCursor(jobName, target, voiceUserId, syncUserId)
+	return
+}
+
+// UndoLastScan removes the most recent page scanned into the user's current job
+func UndoLastScan(voiceUserId, possibleSyncUserId string) (syncUserId, sessionJobName string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	sessionJobName, err = setJobName("", voiceUserId, syncUserId)
+	if err != nil {
+		return
+	}
+	ref := client.Collection("lastScan").Doc(cursorKey(voiceUserId, syncUserId))
+	snap, err := ref.Get(ctx)
+	if err != nil || !snap.Exists() {
+		return syncUserId, sessionJobName, errors.NothingToUndoError{JobName: sessionJobName, UserId: voiceUserId, Context: "UndoLastScan", Log: "no last scan recorded"}
+	}
+	var l lastScanDoc
+	if err = snap.DataTo(&l); err != nil {
+		return syncUserId, sessionJobName, errors.SystemError{JobName: sessionJobName, UserId: voiceUserId, Context: "UndoLastScan", Log: fmt.Sprintf("could not map last scan doc to struct: %s", err)}
+	}
+	if l.JobName != sessionJobName {
+		return syncUserId, sessionJobName, errors.NothingToUndoError{JobName: sessionJobName, UserId: voiceUserId, Context: "UndoLastScan", Log: fmt.Sprintf("last scan was into %s, not the current job", l.JobName)}
+	}
+	c := jobCommandDoc{syncUserId, voiceUserId, PageRemove, sessionJobName, "", time.Now(), l.ScanId}
+	if _, _, err = client.Collection("jobCommand").Add(ctx, c); err != nil {
+		return syncUserId, sessionJobName, errors.SystemError{JobName: sessionJobName, UserId: syncUserId, Context: "UndoLastScan", Log: fmt.Sprintf("could not create page removal command: %s", err)}
+	}
+	// forget the scan, so undoing twice doesn't remove the same page twice
+	if _, err = ref.Delete(ctx); err != nil {
+		return syncUserId, sessionJobName, errors.SystemError{JobName: sessionJobName, UserId: syncUserId, Context: "UndoLastScan", Log: fmt.Sprintf("could not delete last scan doc: %s", err)}
+	}
+	return
+}
+
+func recordLastScan(scanId, jobName, voiceUserId, syncUserId string) error {
+	l := lastScanDoc{ScanId: scanId, JobName: jobName, Created: time.Now()}
+	if _, err := client.Collection("lastScan").Doc(cursorKey(voiceUserId, syncUserId)).Set(ctx, l); err != nil {
+		return errors.SystemError{JobName: jobName, UserId: syncUserId, Context: "recordLastScan", Log: fmt.Sprintf("could not record last scan: %s", err)}
+	}
+	return nil
+}
+
+func findJob(jobName, voiceUserId, syncUserId string) (string, error) {
+	if jobName == "" {
+		return setJobName("", voiceUserId, syncUserId)
+	}
+	name, existing, err := resolveJobName(jobName, voiceUserId, syncUserId)
+	if err != nil {
+		return "", err
+	}
+	if !existing {
+		return "", errors.JobNotFoundError{JobName: name, UserId: voiceUserId, Context: "findJob", Log: fmt.Sprintf("no job named %s", name)}
+	}
+	return name, nil
+}
+
+func sendJobCommand(command, jobName, target, voiceUserId, syncUserId string) error {
+	c := jobCommandDoc{syncUserId, voiceUserId, command, jobName, target, time.Now(), ""}
+	if _, _, err := client.Collection("jobCommand").Add(ctx, c); err != nil {
+		return errors.SystemError{JobName: jobName, UserId: syncUserId, Context: "sendJobCommand", Log: fmt.Sprintf("could not create %s command: %s", command, err)}
+	}
+	return nil
+}
+
+// moveCursor follows jobName to target in the cursor and its history, dropping it when there's no
+// target; deleting the active job leaves the cursor without one but keeps the history
+func moveCursor(jobName, target, voiceUserId, syncUserId string) (activeJobName string, err error) {
+	c, err := getCursorDoc(voiceUserId, syncUserId)
+	if err != nil {
+		return "", nil
+	}
+	if job_names.Canonicalize(c.JobName) != job_names.Canonicalize(jobName) {
+		c.History = renameInHistory(c.History, jobName, target, c.JobName)
+		if err = saveCursor(c, voiceUserId); err != nil || isCursorExpired(c) {
+			return "", err
+		}
+		return c.JobName, nil
+	}
+	c.JobName, c.History = target, renameInHistory(c.History, jobName, target, target)
+	if target != "" {
+		c.Set = time.Now()
+	}
+	return target, saveCursor(c, voiceUserId)
+}
+
+// renameInHistory swaps jobName for target in the history, or drops it with no target, leaving out
+// the active job and any name that's now there twice
+func renameInHistory(history []string, jobName, target, active string) []string {
+	h := []string{}
+	seen := map[string]bool{active: true}
+	for _, j := range history {
+		if job_names.Canonicalize(j) == job_names.Canonicalize(jobName) {
+			j = target
+		}
+		if j == "" || seen[j] {
+			continue
+		}
+		seen[j] = true
+		h = append(h, j)
+	}
+	return h
+}
@@ -0,0 +1,29 @@
+package queue_connect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenameInHistory(t *testing.T) {
+	history := []string{"Tax Docs", "receipts", "school forms"}
+	cases := []struct {
+		name    string
+		jobName string
+		target  string
+		active  string
+		want    []string
+	}{
+		{"renamed", "tax docs", "2026 taxes", "medical", []string{"2026 taxes", "receipts", "school forms"}},
+		{"deleted", "receipts", "", "medical", []string{"Tax Docs", "school forms"}},
+		{"merged into another in the history", "tax docs", "receipts", "medical", []string{"receipts", "school forms"}},
+		{"merged into the active job", "school forms", "medical", "medical", []string{"Tax Docs", "receipts"}},
+		{"not in the history", "medical", "", "", []string{"Tax Docs", "receipts", "school forms"}},
+	}
+	for _, c := range cases {
+		got := renameInHistory(history, c.jobName, c.target, c.active)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
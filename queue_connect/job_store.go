@@ -0,0 +1,98 @@
+package queue_connect
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"sort"
+	"speechLiason/errors"
+	"time"
+)
+
+var jobs JobStore = FirestoreJobStore{}
+
+type Job struct {
+	UserId  string    `firestore:"u"`
+	Name    string    `firestore:"n"`
+	Pages   int       `firestore:"p"`
+	Updated time.Time `firestore:"t"`
+}
+
+type JobStore interface {
+	// ListJobs returns the user's jobs, most recently updated first
+	ListJobs(syncUserId string) ([]Job, error)
+}
+
+type FirestoreJobStore struct{}
+
+func (FirestoreJobStore) ListJobs(syncUserId string) ([]Job, error) {
+	docs, err := client.Collection("job").
+		Where("u", "==", syncUserId).
+		OrderBy("t", firestore.Desc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.SystemError{UserId: syncUserId, Context: "ListJobs", Log: fmt.Sprintf("could not retrieve jobs: %s", err)}
+	}
+	result := make([]Job, 0, len(docs))
+	for _, doc := range docs {
+		var j Job
+		if err = doc.DataTo(&j); err != nil {
+			return nil, errors.SystemError{UserId: syncUserId, Context: "ListJobs", Log: fmt.Sprintf("could not map job doc to struct: %s", err)}
+		}
+		result = append(result, j)
+	}
+	return result, nil
+}
+
+// MemoryJobStore lists jobs from memory, newest first, like the Firestore store
+type MemoryJobStore map[string][]Job
+
+func (m MemoryJobStore) ListJobs(syncUserId string) ([]Job, error) {
+	result := append([]Job(nil), m[syncUserId]...)
+	sort.SliceStable(result, func(i, k int) bool {
+		return result[i].Updated.After(result[k].Updated)
+	})
+	return result, nil
+}
+
+func SetJobStore(s JobStore) {
+	jobs = s
+}
+
+func RecentJobNames(syncUserId string, count int) ([]string, error) {
+	all, err := jobs.ListJobs(syncUserId)
+	if err != nil {
+		return nil, err
+	}
+	if len(all) > count {
+		all = all[:count]
+	}
+	names := make([]string, len(all))
+	for i, j := range all {
+		names[i] = j.Name
+	}
+	return names, nil
+}
+
+func ListJobs(voiceUserId, possibleSyncUserId string, offset, count int) (syncUserId string, page []Job, more bool, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	page, more, err = jobPage(syncUserId, offset, count)
+	return
+}
+
+func jobPage(syncUserId string, offset, count int) (page []Job, more bool, err error) {
+	all, err := jobs.ListJobs(syncUserId)
+	if err != nil {
+		return nil, false, err
+	}
+	if offset >= len(all) {
+		return nil, false, nil
+	}
+	end := offset + count
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[offset:end], end < len(all), nil
+}
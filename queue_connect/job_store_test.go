@@ -0,0 +1,57 @@
+package queue_connect
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestJobPage(t *testing.T) {
+	base := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	var stored []Job
+	for i := 0; i < 7; i++ {
+		stored = append(stored, Job{UserId: "sync-1", Name: fmt.Sprintf("job %d", i), Updated: base.Add(time.Duration(i) * time.Hour)})
+	}
+	SetJobStore(MemoryJobStore{"sync-1": stored})
+	defer SetJobStore(FirestoreJobStore{})
+
+	cases := []struct {
+		offset int
+		names  []string
+		more   bool
+	}{
+		{0, []string{"job 6", "job 5", "job 4"}, true},
+		{3, []string{"job 3", "job 2", "job 1"}, true},
+		{6, []string{"job 0"}, false},
+		{7, nil, false},
+		{12, nil, false},
+	}
+	for _, c := range cases {
+		page, more, err := jobPage("sync-1", c.offset, 3)
+		if err != nil {
+			t.Fatalf("offset %d: unexpected error: %s", c.offset, err)
+		}
+		if more != c.more {
+			t.Errorf("offset %d: more = %v, want %v", c.offset, more, c.more)
+		}
+		if len(page) != len(c.names) {
+			t.Errorf("offset %d: got %d jobs, want %d", c.offset, len(page), len(c.names))
+			continue
+		}
+		for i, j := range page {
+			if j.Name != c.names[i] {
+				t.Errorf("offset %d: job %d is %q, want %q", c.offset, i, j.Name, c.names[i])
+			}
+		}
+	}
+}
+
+func TestJobPageForUserWithoutJobs(t *testing.T) {
+	SetJobStore(MemoryJobStore{})
+	defer SetJobStore(FirestoreJobStore{})
+
+	page, more, err := jobPage("sync-1", 0, 3)
+	if err != nil || more || len(page) != 0 {
+		t.Errorf("got %v, %v, %v; want an empty last page", page, more, err)
+	}
+}
@@ -3,42 +3,67 @@ package queue_connect
 import (
 	"cloud.google.com/go/firestore"
 	"context"
+	"crypto/rand"
 	"fmt"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"math/big"
 	"os"
-	"regexp"
 	"speechLiason/cloud_resources"
+	"speechLiason/delivery"
 	"speechLiason/errors"
+	"speechLiason/job_names"
+	"speechLiason/voice_request"
 	"time"
 )
 
 var client *firestore.Client
 var ctx context.Context
 
-const cursorTtl = 5 * time.Minute
-const syncTtl = 3 * time.Minute
+// connections counts the callers holding the client open, so a handler nested inside another shares its connection
+var connections int
+
+var cursorTtl = durationFromEnv("CURSOR_TTL", 5*time.Minute)
+var syncTtl = durationFromEnv("SYNC_TTL", 3*time.Minute)
+
+const maxTtl = 12 * time.Hour
+const skillCodeLength = 6
+const syncPollInterval = 1 * time.Second
+const cursorHistoryLength = 5
 
 type scanDoc struct {
-	UserId      string `firestore:"u"`
-	VoiceUserId string `firestore:"v"`
-	JobName     string `firestore:"j"`
-	Method      string `firestore:"m"`
-	Destination string `firestore:"d"`
+	UserId      string                 `firestore:"u"`
+	VoiceUserId string                 `firestore:"v"`
+	JobName     string                 `firestore:"j"`
+	Method      string                 `firestore:"m"`
+	Destination string                 `firestore:"d"`
+	Created     time.Time              `firestore:"t"`
+	State       string                 `firestore:"s"`
+	Payload     map[string]interface{} `firestore:"p"`
+	Options     delivery.Options       `firestore:"o"`
 }
 
 type cursorDoc struct {
 	Set     time.Time `firestore:"s"`
 	UserId  string    `firestore:"u"`
 	JobName string    `firestore:"j"`
+	History []string  `firestore:"h"`
 }
 
 type deliverDoc struct {
-	UserId      string `firestore:"u"`
-	VoiceUserId string `firestore:"v"`
-	JobName     string `firestore:"j"`
-	Method      string `firestore:"m"`
-	Destination string `firestore:"d"`
+	UserId      string                 `firestore:"u"`
+	VoiceUserId string                 `firestore:"v"`
+	JobName     string                 `firestore:"j"`
+	Method      string                 `firestore:"m"`
+	Destination string                 `firestore:"d"`
+	Created     time.Time              `firestore:"t"`
+	State       string                 `firestore:"s"`
+	Payload     map[string]interface{} `firestore:"p"`
+	Group       string                 `firestore:"g"`
+	Scheduled   time.Time              `firestore:"w"`
+	Options     delivery.Options       `firestore:"o"`
+	// a digest bundles every job it covers into one delivery; JobName is only set when there's one
+	Jobs []string `firestore:"b"`
 }
 
 type syncDoc struct {
@@ -49,124 +74,271 @@ type syncDoc struct {
 	Accepted          bool   `firestore:"a"`
 	Initialized       int64  `firestore:"t"`
 	UserId            string `firestore:"u"`
+	SkillCode         string `firestore:"k"`
+	AccountName       string `firestore:"n"`
 }
 
 func InitConnection(projectName string, key []byte) (err error) {
+	if connections > 0 {
+		connections++
+		return nil
+	}
 	ctx = context.Background()
 	opts := option.WithCredentialsJSON(key)
 	client, err = firestore.NewClient(ctx, projectName, opts)
 	if err != nil {
 		return errors.SystemError{JobName: projectName, UserId: "", Context: "InitConnection", Log: fmt.Sprintf("could not initialize connection: %s", err)}
 	}
+	connections = 1
 	return err
 }
 
 func CloseConnection() {
+	connections--
+	if connections > 0 {
+		return
+	}
+	connections = 0
 	_ = client.Close()
 }
 
 func SendScanCommand(jobName string, voiceUserId, possibleSyncUserId string) (syncUserId, sessionJobName string, err error) {
-	sessionJobName, err = setJobName(jobName, voiceUserId)
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
 	if err != nil {
 		return
 	}
-	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	sessionJobName, err = setJobName(jobName, voiceUserId, syncUserId)
 	if err != nil {
 		return
 	}
-	s := scanDoc{syncUserId, voiceUserId, sessionJobName,"", ""}
-	if _, _, err := client.Collection("scan").Add(ctx, s); err != nil {
+	s := scanDoc{syncUserId, voiceUserId, sessionJobName, "", "", time.Now(), CommandPending, nil, delivery.Options{}}
+	ref, _, err := client.Collection("scan").Add(ctx, s)
+	if err != nil {
 		return syncUserId, sessionJobName, errors.SystemError{JobName: sessionJobName, UserId: syncUserId, Context: "SendScanCommand", Log: fmt.Sprintf("there was a problem creating the scan command: %s", err)}
 	}
-	_, _, err = SetCursor(sessionJobName, voiceUserId, syncUserId)
+	if err = recordLastScan(ref.ID, sessionJobName, voiceUserId, syncUserId); err != nil {
+		return
+	}
+	err = writeCursor(sessionJobName, voiceUserId, syncUserId)
 	return
 }
 
 // TODO: fix delivery, log output [could not create delivery command: firestore: nil DocumentRef]
-func SendDeliveryCommand(jobName, voiceUserId, possibleSyncUserId, method, destination string) (syncUserId, sessionJobName string, err error) {
-	sessionJobName, err = setJobName(jobName, voiceUserId)
-	if err != nil {
-		return possibleSyncUserId, "", err
-	}
-	if method != "email" {
-		return possibleSyncUserId, sessionJobName, errors.UnsupportedOperationError{ContextualError: errors.ContextualError{JobName: sessionJobName, UserId: voiceUserId, Context: "SendDeliveryCommand", Log: fmt.Sprintf("method for delivery %s not yet supported", method)}, ErroneousOperation: method}
-	}
-	if !isValidEmail(destination) {
-		return possibleSyncUserId, sessionJobName, errors.InvalidInputError{ContextualError: errors.ContextualError{JobName: sessionJobName, UserId: voiceUserId, Context: "SendDeliveryCommand", Log: fmt.Sprintf("invalid email address %s", destination)}, ErroneousInput: "email address"}
+func SendDeliveryCommand(jobName, voiceUserId, possibleSyncUserId, method, target, apiToken string) (syncUserId, sessionJobName string, err error) {
+	return SendMultiDeliveryCommand(jobName, voiceUserId, possibleSyncUserId, []DeliveryTarget{{method, target, ""}}, apiToken)
+}
+
+func SetCursor(jobName, voiceUserId, possibleSyncUserId string) (syncUserId, sessionJobName string, err error) {
+	if jobName == "" {
+		return possibleSyncUserId, "", errors.MissingJobNameError{JobName: jobName, UserId: voiceUserId, Context: "SetCursor", Log: "could not set cursor without a job name"}
 	}
 	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
 	if err != nil {
 		return
 	}
-	d := deliverDoc{syncUserId, voiceUserId, sessionJobName, method, destination}
-	_, _, err = client.Collection("delivery").Add(ctx, d)
+	sessionJobName, _, err = resolveJobName(jobName, voiceUserId, syncUserId)
 	if err != nil {
-		return syncUserId, sessionJobName, errors.SystemError{JobName: sessionJobName, UserId: voiceUserId, Context: "SendDeliveryCommand", Log: fmt.Sprintf("could not create delivery command: %s", err)}
+		return
 	}
+	err = writeCursor(sessionJobName, voiceUserId, syncUserId)
 	return
 }
 
-func SetCursor(jobName, voiceUserId, possibleSyncUserId string) (syncUserId, sessionJobName string, err error) {
-	if jobName == "" {
-		return possibleSyncUserId, "", errors.MissingJobNameError{JobName: jobName, UserId: voiceUserId, Context: "SetCursor", Log: "could not set cursor without a job name"}
+// SetCursorAsSpoken skips matching against existing jobs, for when the user has
+// turned down the job we thought they meant
+func SetCursorAsSpoken(jobName, voiceUserId, possibleSyncUserId string) (syncUserId, sessionJobName string, err error) {
+	sessionJobName = job_names.Canonicalize(jobName)
+	if sessionJobName == "" {
+		return possibleSyncUserId, "", errors.MissingJobNameError{JobName: jobName, UserId: voiceUserId, Context: "SetCursorAsSpoken", Log: "could not set cursor without a job name"}
 	}
-	sessionJobName = jobName
-	ref := client.Collection("cursor").Doc(voiceUserId)
 	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
 	if err != nil {
 		return
 	}
-	c := cursorDoc{UserId: syncUserId, Set: time.Now(), JobName: jobName}
-	_, err = ref.Set(ctx, c)
-	if err != nil {
-		err = errors.SystemError{JobName: jobName, UserId: voiceUserId, Context: "SetCursor", Log: fmt.Sprintf("could not set new cursor doc: %s", err)}
-	}
+	err = writeCursor(sessionJobName, voiceUserId, syncUserId)
 	return
 }
 
-func SyncAccounts(spokenCode string, voiceUserId string, deviceAddress cloud_resources.DeviceAddress) (err error) {
+func SyncAccounts(spokenCode string, voiceUserId string, deviceAddress cloud_resources.DeviceAddress) (syncDocId string, err error) {
 	s, err := getSyncDoc(spokenCode, "")
 	if err != nil {
-		return err
+		return "", err
 	}
 	if err = checkSyncDocExpired(s); err != nil {
-		return err
+		return "", err
 	}
 	s.VoiceUserId = voiceUserId
 	s.SpokenCode = spokenCode
 	s.VoiceUserLocation = deviceAddress.PromptedLocation
 	err = setSyncDoc(s, s.UserId)
+	return s.UserId, err
+}
+
+func AwaitSyncAcceptance(syncDocId string, wait time.Duration) (syncUserId, accountName string, accepted bool, err error) {
+	deadline := time.Now().Add(wait)
+	for {
+		s, err := getSyncDocById(syncDocId)
+		if err != nil {
+			return "", "", false, err
+		}
+		if s.Accepted {
+			return s.UserId, s.AccountName, true, nil
+		}
+		if time.Now().Add(syncPollInterval).After(deadline) {
+			return "", "", false, nil
+		}
+		time.Sleep(syncPollInterval)
+	}
+}
+
+func StartReverseSync(voiceUserId string, deviceAddress cloud_resources.DeviceAddress) (code string, err error) {
+	code, err = generateSkillCode()
+	if err != nil {
+		return "", err
+	}
+	// keyed by voice user until the dashboard fills in the sync user and accepts it
+	s := syncDoc{
+		VoiceUserId:       voiceUserId,
+		VoiceUserLocation: deviceAddress.PromptedLocation,
+		SkillCode:         code,
+		Accepted:          false,
+		Initialized:       time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	err = setSyncDoc(s, voiceUserId)
 	return
 }
 
-func QuickScanAndDeliver(voiceUserId, possibleSyncUserId, method, destination string) (syncUserId string, err error) {
+func Unlink(voiceUserId string) (remaining *cloud_resources.UserMapping, err error) {
+	mappedUserId, um, err := getUserMapping(voiceUserId)
+	if err != nil {
+		return nil, err
+	}
+	if um == nil {
+		return nil, deleteAcceptedSyncDocs(voiceUserId, "")
+	}
+	unlinked := um.SyncUserId
+	remaining, err = cloud_resources.RemoveLinkedAccount(mappedUserId, um.ActiveAccount)
+	if err != nil {
+		return nil, err
+	}
+	if err = clearCursor(voiceUserId, unlinked); err != nil {
+		return nil, err
+	}
+	if remaining == nil {
+		return nil, deleteAcceptedSyncDocs(mappedUserId, "")
+	}
+	return remaining, deleteAcceptedSyncDocs(mappedUserId, unlinked)
+}
+
+func CompleteResync(voiceUserId, syncUserId string) error {
+	previousSyncUserId := ""
+	um, err := cloud_resources.GetUserMapping(voiceUserId)
+	if err != nil {
+		return err
+	}
+	if um != nil {
+		previousSyncUserId = um.SyncUserId
+	}
+	if err = cloud_resources.ReplaceUserMapping(voiceUserId, previousSyncUserId, syncUserId); err != nil {
+		return err
+	}
+	if previousSyncUserId == "" || previousSyncUserId == syncUserId {
+		return nil
+	}
+	// the old cursor points at a job in the previous account
+	if err = clearCursor(voiceUserId, previousSyncUserId); err != nil {
+		return err
+	}
+	return deleteAcceptedSyncDocs(voiceUserId, previousSyncUserId)
+}
+
+// LinkAccount saves the accepted account under accountName and returns the name it was saved under;
+// with no name, it keeps the one it's already linked under or picks one no other account is using
+func LinkAccount(voiceUserId, accountName, syncUserId string) (savedName string, err error) {
+	if accountName == "" {
+		if accountName, err = unusedAccountName(voiceUserId, syncUserId); err != nil {
+			return "", err
+		}
+	}
+	return accountName, cloud_resources.SaveUserMapping(voiceUserId, accountName, syncUserId)
+}
+
+func unusedAccountName(voiceUserId, syncUserId string) (string, error) {
+	um, err := cloud_resources.GetUserMapping(voiceUserId)
+	if err != nil {
+		return "", err
+	}
+	if um == nil {
+		return cloud_resources.DefaultAccountName, nil
+	}
+	for name, id := range um.Accounts {
+		if id == syncUserId {
+			return name, nil
+		}
+	}
+	name := cloud_resources.DefaultAccountName
+	for n := 2; um.Accounts[name] != ""; n++ {
+		name = fmt.Sprintf("account %d", n)
+	}
+	return name, nil
+}
+
+func SwitchAccount(voiceUserId, accountName string) (syncUserId, jobName string, err error) {
+	mappedUserId, _, err := getUserMapping(voiceUserId)
+	if err != nil {
+		return "", "", err
+	}
+	um, err := cloud_resources.SwitchUserMapping(mappedUserId, accountName)
+	if err != nil {
+		return "", "", err
+	}
+	syncUserId = um.SyncUserId
+	// pick up where this account left off, if its cursor is still fresh
+	jobName, err = setJobName("", voiceUserId, syncUserId)
+	if err != nil {
+		return syncUserId, "", nil
+	}
+	return
+}
+
+func GetLinkedAccounts(voiceUserId string) (activeAccount string, accountNames []string, err error) {
+	_, um, err := getUserMapping(voiceUserId)
+	if err != nil {
+		return "", nil, err
+	}
+	if um == nil {
+		return "", nil, errors.UserAccountNotSyncedError{UserId: voiceUserId, Context: "GetLinkedAccounts", Log: fmt.Sprintf("voice user %s has no linked accounts", voiceUserId)}
+	}
+	return um.ActiveAccount, um.AccountNames(), nil
+}
+
+func QuickScanAndDeliver(voiceUserId, possibleSyncUserId, method, target, apiToken string) (syncUserId string, err error) {
 	t := string(time.Now().Unix())
 	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
 	if err != nil {
 		return
 	}
-	if method != "email" {
-		return "", errors.UnsupportedOperationError{ContextualError: errors.ContextualError{JobName: t, UserId: voiceUserId, Context: "SendDeliveryCommand", Log: fmt.Sprintf("method for delivery %s not yet supported", method)}, ErroneousOperation: method}
-	}
-	if !isValidEmail(destination) {
-		return "", errors.InvalidInputError{ContextualError: errors.ContextualError{JobName: t, UserId: voiceUserId, Context: "SendDeliveryCommand", Log: fmt.Sprintf("invalid email address %s", destination)}, ErroneousInput: "email address"}
+	dest, payload, opts, err := resolveDelivery(method, target, "", apiToken, voiceUserId, syncUserId)
+	if err != nil {
+		return syncUserId, err
 	}
-	s := scanDoc{syncUserId, voiceUserId, t,method, destination}
+	s := scanDoc{syncUserId, voiceUserId, t, dest.Method, dest.Address, time.Now(), CommandPending, payload, opts}
 	if _, _, err := client.Collection("scan").Add(ctx, s); err != nil {
 		return syncUserId, errors.SystemError{JobName: t, UserId: syncUserId, Context: "SendScanCommand", Log: fmt.Sprintf("there was a problem creating the scan command: %s", err)}
 	}
 	return
 }
 
-func setJobName(inputName, voiceUserId string) (outputName string, err error) {
+func setJobName(inputName, voiceUserId, syncUserId string) (outputName string, err error) {
 	if inputName != "" {
-		return inputName, nil
+		outputName, _, err = resolveJobName(inputName, voiceUserId, syncUserId)
+		return
 	}
-	c, err := getCursorDoc(voiceUserId)
+	c, err := getCursorDoc(voiceUserId, syncUserId)
 	if err != nil {
 		return "", err
 	}
-	if c == (cursorDoc{}) {
+	if c.JobName == "" {
 		return "", errors.CursorNotFoundError{JobName: "", UserId: voiceUserId, Context: "setJobName", Log: "no error retrieving cursor doc, but it came back as nil"}
 	}
 	outputName = c.JobName
@@ -176,6 +348,96 @@ func setJobName(inputName, voiceUserId string) (outputName string, err error) {
 	return
 }
 
+func resolveJobName(spokenName, voiceUserId, syncUserId string) (name string, existing bool, err error) {
+	name = job_names.Canonicalize(spokenName)
+	if name == "" {
+		return "", false, errors.MissingJobNameError{JobName: spokenName, UserId: voiceUserId, Context: "resolveJobName", Log: "job name was empty once canonicalized"}
+	}
+	all, err := jobs.ListJobs(syncUserId)
+	if err != nil {
+		return "", false, err
+	}
+	names := make([]string, len(all))
+	for i, j := range all {
+		names[i] = j.Name
+	}
+	best, score := job_names.Match(name, names)
+	if score == 1 {
+		return best, true, nil
+	}
+	if score >= job_names.PossibleMatch {
+		return "", false, errors.UncertainJobNameError{ContextualError: errors.ContextualError{JobName: name, UserId: voiceUserId, Context: "resolveJobName", Log: fmt.Sprintf("spoken job %s might be %s (score %.2f)", name, best, score)}, SpokenName: name, Candidate: best}
+	}
+	return name, false, nil
+}
+
+func SwitchToPreviousJob(voiceUserId, possibleSyncUserId string) (syncUserId, sessionJobName string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	c, err := getCursorDoc(voiceUserId, syncUserId)
+	if err != nil || len(c.History) == 0 {
+		return syncUserId, "", errors.NoPreviousJobError{UserId: voiceUserId, Context: "SwitchToPreviousJob", Log: "no cursor history"}
+	}
+	// jobs deleted since they were in the history can't be gone back to
+	if c.History, err = existingJobs(c.History, syncUserId); err != nil {
+		return
+	}
+	if len(c.History) == 0 {
+		return syncUserId, "", errors.NoPreviousJobError{UserId: voiceUserId, Context: "SwitchToPreviousJob", Log: "no jobs left in cursor history"}
+	}
+	sessionJobName = c.History[0]
+	err = saveCursor(cursorDoc{UserId: syncUserId, Set: time.Now(), JobName: sessionJobName, History: cursorHistory(c, sessionJobName)}, voiceUserId)
+	return
+}
+
+// ResumeJob points the cursor at a job the user confirmed, keeping its name as stored
+func ResumeJob(jobName, voiceUserId, possibleSyncUserId string) (syncUserId string, err error) {
+	if jobName == "" {
+		return possibleSyncUserId, errors.MissingJobNameError{JobName: jobName, UserId: voiceUserId, Context: "ResumeJob", Log: "could not resume without a job name"}
+	}
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	err = writeCursor(jobName, voiceUserId, syncUserId)
+	return
+}
+
+func writeCursor(jobName, voiceUserId, syncUserId string) error {
+	// a missing cursor just means there's no history to carry forward
+	previous, _ := getCursorDoc(voiceUserId, syncUserId)
+	return saveCursor(cursorDoc{UserId: syncUserId, Set: time.Now(), JobName: jobName, History: cursorHistory(previous, jobName)}, voiceUserId)
+}
+
+func saveCursor(c cursorDoc, voiceUserId string) error {
+	ref := client.Collection("cursor").Doc(cursorKey(voiceUserId, c.UserId))
+	if _, err := ref.Set(ctx, c); err != nil {
+		return errors.SystemError{JobName: c.JobName, UserId: voiceUserId, Context: "saveCursor", Log: fmt.Sprintf("could not set new cursor doc: %s", err)}
+	}
+	return nil
+}
+
+// existingJobs keeps the names that still belong to one of the user's jobs, in order
+func existingJobs(names []string, syncUserId string) ([]string, error) {
+	all, err := jobs.ListJobs(syncUserId)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool, len(all))
+	for _, j := range all {
+		stored[job_names.Canonicalize(j.Name)] = true
+	}
+	kept := []string{}
+	for _, n := range names {
+		if stored[job_names.Canonicalize(n)] {
+			kept = append(kept, n)
+		}
+	}
+	return kept, nil
+}
+
 func getUserId(voiceUserId, possibleSyncUserId string) (userId string, err error) {
 	// check session attributes
 	if possibleSyncUserId != "" {
@@ -187,7 +449,7 @@ func getUserId(voiceUserId, possibleSyncUserId string) (userId string, err error
 	if err != nil {
 		return
 	}
-	if um != &(cloud_resources.UserMapping{}) {
+	if um != nil && um.SyncUserId != "" {
 		userId = um.SyncUserId
 		return
 	}
@@ -196,7 +458,11 @@ func getUserId(voiceUserId, possibleSyncUserId string) (userId string, err error
 	if err != nil {
 		switch err.(type) {
 		case errors.SyncDocNotFoundError:
-			err = errors.UserAccountNotSyncedError{Context: "getUserId", Log: fmt.Sprintf("voice user %s has not yet synced device")}
+			// recognized speakers without their own link share the account-level one
+			if a := voice_request.AccountUserId(voiceUserId); a != voiceUserId {
+				return getUserId(a, "")
+			}
+			err = errors.UserAccountNotSyncedError{Context: "getUserId", Log: fmt.Sprintf("voice user %s has not yet synced device", voiceUserId)}
 			break
 		default:
 			break
@@ -204,7 +470,7 @@ func getUserId(voiceUserId, possibleSyncUserId string) (userId string, err error
 		return
 	}
 	userId = s.UserId
-	if err = cloud_resources.SaveUserMapping(voiceUserId, userId); err != nil {
+	if err = cloud_resources.SaveUserMapping(voiceUserId, cloud_resources.DefaultAccountName, userId); err != nil {
 		e := errors.SystemError{UserId:userId, Context:"getUserId", Log:fmt.Sprintf("could not persist sync doc's info to user-mapping database: %s", err)}
 		fmt.Println(e)
 		return userId, nil
@@ -212,8 +478,22 @@ func getUserId(voiceUserId, possibleSyncUserId string) (userId string, err error
 	return
 }
 
-func getCursorDoc(voiceUserId string) (cursor cursorDoc, err error) {
-	snap, err := client.Doc("cursor/" + voiceUserId).Get(ctx)
+// getUserMapping finds the mapping getUserId would resolve through, falling back to the account-level
+// one for recognized speakers without their own, and returns whose it is
+func getUserMapping(voiceUserId string) (mappedUserId string, um *cloud_resources.UserMapping, err error) {
+	um, err = cloud_resources.GetUserMapping(voiceUserId)
+	if err != nil || um != nil {
+		return voiceUserId, um, err
+	}
+	if a := voice_request.AccountUserId(voiceUserId); a != voiceUserId {
+		um, err = cloud_resources.GetUserMapping(a)
+		return a, um, err
+	}
+	return voiceUserId, nil, nil
+}
+
+func getCursorDoc(voiceUserId, syncUserId string) (cursor cursorDoc, err error) {
+	snap, err := client.Doc("cursor/" + cursorKey(voiceUserId, syncUserId)).Get(ctx)
 	if err != nil {
 		return cursorDoc{}, errors.CursorNotFoundError{JobName: "", UserId: voiceUserId, Context: "getCursorDoc", Log: "cursor doc snapshot doesn't exist"}
 	}
@@ -227,14 +507,200 @@ func getCursorDoc(voiceUserId string) (cursor cursorDoc, err error) {
 	return
 }
 
+// cursors are kept per linked account, so switching accounts doesn't lose the other account's job
+func cursorKey(voiceUserId, syncUserId string) string {
+	return voiceUserId + "_" + syncUserId
+}
+
+func clearCursor(voiceUserId, syncUserId string) error {
+	if _, err := client.Doc("cursor/" + cursorKey(voiceUserId, syncUserId)).Delete(ctx); err != nil {
+		return errors.SystemError{UserId: voiceUserId, Context: "clearCursor", Log: fmt.Sprintf("could not delete cursor doc: %s", err)}
+	}
+	return nil
+}
+
+// cursorHistory keeps the most recent other jobs, newest first
+func cursorHistory(previous cursorDoc, jobName string) []string {
+	h := []string{}
+	if previous.JobName != "" && previous.JobName != jobName {
+		h = append(h, previous.JobName)
+	}
+	for _, j := range previous.History {
+		if j != jobName && j != previous.JobName {
+			h = append(h, j)
+		}
+	}
+	if len(h) > cursorHistoryLength {
+		h = h[:cursorHistoryLength]
+	}
+	return h
+}
+
+// cursors slide: every scan into the job rewrites the cursor, so the TTL is measured from the last scan
 func isCursorExpired(c cursorDoc) bool {
 	s := time.Since(c.Set)
-	return s.Minutes() > cursorTtl.Minutes()
+	return s.Minutes() > cursorTtlFor(c.UserId).Minutes()
+}
+
+func SetCursorTtl(voiceUserId, possibleSyncUserId string, ttl time.Duration) (syncUserId string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	if ttl < time.Minute || ttl > maxTtl {
+		return syncUserId, errors.InvalidInputError{ContextualError: errors.ContextualError{UserId: voiceUserId, Context: "SetCursorTtl", Log: fmt.Sprintf("cursor ttl %s out of range", ttl)}, ErroneousInput: "length of time"}
+	}
+	prefs, err := cloud_resources.GetUserPreferences(syncUserId)
+	if err != nil {
+		return
+	}
+	prefs.CursorTtlMinutes = int(ttl.Minutes())
+	err = cloud_resources.SaveUserPreferences(prefs)
+	return
+}
+
+// SetDeliveryFormat changes the user's default packaging and returns what it now is; choosing a
+// format starts its packaging over, while zip and protected add to what's already chosen
+func SetDeliveryFormat(voiceUserId, possibleSyncUserId, choice string) (syncUserId string, opts delivery.Options, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	if err = delivery.ValidateChoice(choice); err != nil {
+		return
+	}
+	prefs, err := cloud_resources.GetUserPreferences(syncUserId)
+	if err != nil {
+		return
+	}
+	switch choice {
+	case delivery.ChoiceZip:
+		prefs.ZipDeliveries = true
+	case delivery.ChoiceProtected:
+		// a protected default would fail every delivery until a password was set up
+		if prefs.PdfPasswordRef == "" {
+			return syncUserId, opts, errors.PdfPasswordNotSetError{UserId: voiceUserId, Context: "SetDeliveryFormat", Log: "protected default asked for with no password set up"}
+		}
+		prefs.ProtectDeliveries = true
+	default:
+		prefs.DeliveryFormat, prefs.ZipDeliveries, prefs.ProtectDeliveries = choice, false, false
+	}
+	if opts, err = delivery.ResolveOptions("", prefs, nil); err != nil {
+		return
+	}
+	err = cloud_resources.SaveUserPreferences(prefs)
+	return
+}
+
+// SetPdfPassword lets the user's deliveries be password protected, or stops them being, in which
+// case a protected default is dropped
+func SetPdfPassword(voiceUserId, possibleSyncUserId string, enabled bool) (syncUserId string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	prefs, err := cloud_resources.GetUserPreferences(syncUserId)
+	if err != nil {
+		return
+	}
+	prefs.PdfPasswordRef = ""
+	if enabled {
+		prefs.PdfPasswordRef = delivery.PdfPasswordRef(syncUserId)
+	} else {
+		prefs.ProtectDeliveries = false
+	}
+	err = cloud_resources.SaveUserPreferences(prefs)
+	return
+}
+
+// SetAttachmentLimit caps each part of a delivery at megabytes; zero leaves it to the method
+func SetAttachmentLimit(voiceUserId, possibleSyncUserId string, megabytes int) (syncUserId string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	if megabytes < 0 {
+		return syncUserId, errors.InvalidInputError{ContextualError: errors.ContextualError{UserId: voiceUserId, Context: "SetAttachmentLimit", Log: fmt.Sprintf("attachment limit %d out of range", megabytes)}, ErroneousInput: "attachment size"}
+	}
+	prefs, err := cloud_resources.GetUserPreferences(syncUserId)
+	if err != nil {
+		return
+	}
+	prefs.MaxAttachmentMB = megabytes
+	err = cloud_resources.SaveUserPreferences(prefs)
+	return
+}
+
+// per-user preferences override the configured TTLs; failing to read them falls back to the configured ones
+func cursorTtlFor(syncUserId string) time.Duration {
+	prefs, err := cloud_resources.GetUserPreferences(syncUserId)
+	if err != nil {
+		fmt.Println(err)
+		return cursorTtl
+	}
+	if prefs.CursorTtlMinutes > 0 {
+		return time.Duration(prefs.CursorTtlMinutes) * time.Minute
+	}
+	return cursorTtl
+}
+
+func syncTtlFor(syncUserId string) time.Duration {
+	prefs, err := cloud_resources.GetUserPreferences(syncUserId)
+	if err != nil {
+		fmt.Println(err)
+		return syncTtl
+	}
+	if prefs.SyncTtlMinutes > 0 {
+		return time.Duration(prefs.SyncTtlMinutes) * time.Minute
+	}
+	return syncTtl
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// resolveDelivery works out where a delivery goes and how it's packaged; with no method, a named
+// contact's default method is used
+func resolveDelivery(method, target, format, apiToken, voiceUserId, syncUserId string) (d delivery.Destination, payload map[string]interface{}, opts delivery.Options, err error) {
+	r := requester(voiceUserId, syncUserId, apiToken, target)
+	if method == "" && target != "" {
+		c, err := delivery.FindContact(r, target)
+		if err != nil {
+			return d, nil, opts, err
+		}
+		method = c.DefaultMethod
+	}
+	m, err := delivery.Lookup(method)
+	if err != nil {
+		return d, nil, opts, err
+	}
+	d, err = m.ResolveDestination(r)
+	if err != nil {
+		return d, nil, opts, err
+	}
+	if err = m.ValidateDestination(d.Address); err != nil {
+		return d, nil, opts, err
+	}
+	// like the TTLs, packaging falls back to the defaults rather than failing the delivery
+	prefs, err := cloud_resources.GetUserPreferences(syncUserId)
+	if err != nil {
+		fmt.Println(err)
+		prefs = cloud_resources.UserPreferences{SyncUserId: syncUserId}
+	}
+	if opts, err = delivery.ResolveOptions(format, prefs, m); err != nil {
+		return d, nil, opts, err
+	}
+	d.Method = m.Name()
+	return d, m.Payload(d), opts, nil
 }
 
-func isValidEmail(email string) bool {
-	re := regexp.MustCompile(`^"[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}"$`)
-	return re.MatchString(email)
+func requester(voiceUserId, syncUserId, apiToken, target string) delivery.Requester {
+	return delivery.Requester{VoiceUserId: voiceUserId, SyncUserId: syncUserId, ApiToken: apiToken, Target: target, Client: client, Ctx: ctx}
 }
 
 func getSyncDoc(spokenCode, voiceUserId string) (syncDoc, error) {
@@ -268,6 +734,50 @@ func getSyncDoc(spokenCode, voiceUserId string) (syncDoc, error) {
 	return s, nil
 }
 
+func getSyncDocById(syncDocId string) (s syncDoc, err error) {
+	snap, err := client.Doc("sync/" + syncDocId).Get(ctx)
+	if err != nil || !snap.Exists() {
+		return syncDoc{}, errors.SyncDocNotFoundError{Context: "getSyncDocById", Log: fmt.Sprintf("sync doc %s doesn't exist", syncDocId)}
+	}
+	if err = snap.DataTo(&s); err != nil {
+		return syncDoc{}, errors.SystemError{Context: "getSyncDocById", Log: fmt.Sprintf("could not map sync doc to struct: %s", err)}
+	}
+	return
+}
+
+func deleteAcceptedSyncDocs(voiceUserId, syncUserId string) error {
+	q, err := getQuery("", voiceUserId)
+	if err != nil {
+		return err
+	}
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return errors.SystemError{UserId: voiceUserId, Context: "deleteAcceptedSyncDocs", Log: fmt.Sprintf("could not retrieve accepted sync docs: %s", err)}
+	}
+	b := client.Batch()
+	n := 0
+	for _, doc := range docs {
+		// reverse sync docs are keyed by voice user, so the sync user is read from the doc; an empty
+		// sync user clears them all
+		var s syncDoc
+		if err = doc.DataTo(&s); err != nil {
+			return errors.SystemError{UserId: voiceUserId, Context: "deleteAcceptedSyncDocs", Log: fmt.Sprintf("could not map sync doc to struct: %s", err)}
+		}
+		if syncUserId != "" && s.UserId != syncUserId {
+			continue
+		}
+		b.Delete(doc.Ref)
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	if _, err = b.Commit(ctx); err != nil {
+		return errors.SystemError{UserId: voiceUserId, Context: "deleteAcceptedSyncDocs", Log: fmt.Sprintf("could not delete accepted sync docs: %s", err)}
+	}
+	return nil
+}
+
 func getQuery(syncCode, voiceUserId string) (firestore.Query, error) {
 	if syncCode != "" {
 		return client.Collection("sync").
@@ -291,8 +801,20 @@ func setSyncDoc(doc syncDoc, syncUserId string) error {
 	return nil
 }
 
+func generateSkillCode() (string, error) {
+	code := ""
+	for i := 0; i < skillCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", errors.SystemError{Context: "generateSkillCode", Log: fmt.Sprintf("could not generate sync code: %s", err)}
+		}
+		code += n.String()
+	}
+	return code, nil
+}
+
 func checkSyncDocExpired(s syncDoc) error {
-	t := time.Now().UTC().Add(syncTtl * -1)
+	t := time.Now().UTC().Add(syncTtlFor(s.UserId) * -1)
 	i := time.Unix(s.Initialized / 1000, 0)
 	_,_ = fmt.Fprintf(os.Stdout, "current time: %s; initialized time: %s", t, i)
 	if i.After(t) {
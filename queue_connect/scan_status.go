@@ -0,0 +1,95 @@
+package queue_connect
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"speechLiason/errors"
+	"time"
+)
+
+// command states; the agent moves scan and delivery docs out of pending once it has handled
+// them, and skips any it finds cancelled
+const (
+	CommandPending   = "pending"
+	CommandCompleted = "completed"
+	CommandFailed    = "failed"
+	CommandCancelled = "cancelled"
+	// scheduled deliveries wait here until the scheduler releases them to pending
+	CommandScheduled = "scheduled"
+)
+
+type JobStatus struct {
+	JobName     string
+	Pending     int
+	Completed   int
+	Failed      int
+	LastScanned time.Time
+}
+
+func (s JobStatus) Pages() int {
+	return s.Pending + s.Completed + s.Failed
+}
+
+func GetJobStatus(jobName, voiceUserId, possibleSyncUserId string) (syncUserId string, status JobStatus, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	status.JobName, err = setJobName(jobName, voiceUserId, syncUserId)
+	if err != nil {
+		return
+	}
+	docs, err := client.Collection("scan").
+		Where("u", "==", syncUserId).
+		Where("j", "==", status.JobName).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return syncUserId, status, errors.SystemError{JobName: status.JobName, UserId: syncUserId, Context: "GetJobStatus", Log: fmt.Sprintf("could not retrieve scan docs: %s", err)}
+	}
+	for _, doc := range docs {
+		var s scanDoc
+		if err = doc.DataTo(&s); err != nil {
+			return syncUserId, status, errors.SystemError{JobName: status.JobName, UserId: syncUserId, Context: "GetJobStatus", Log: fmt.Sprintf("could not map scan doc to struct: %s", err)}
+		}
+		switch s.State {
+		case CommandCancelled:
+			continue
+		case CommandCompleted:
+			status.Completed++
+		case CommandFailed:
+			status.Failed++
+		default:
+			status.Pending++
+		}
+		if s.Created.After(status.LastScanned) {
+			status.LastScanned = s.Created
+		}
+	}
+	return
+}
+
+func GetLastScanStatus(voiceUserId, possibleSyncUserId string) (syncUserId, jobName, state string, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	docs, err := client.Collection("scan").
+		Where("u", "==", syncUserId).
+		OrderBy("t", firestore.Desc).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return syncUserId, "", "", errors.SystemError{UserId: syncUserId, Context: "GetLastScanStatus", Log: fmt.Sprintf("could not retrieve last scan doc: %s", err)}
+	}
+	if len(docs) == 0 {
+		return syncUserId, "", "", nil
+	}
+	var s scanDoc
+	if err = docs[0].DataTo(&s); err != nil {
+		return syncUserId, "", "", errors.SystemError{UserId: syncUserId, Context: "GetLastScanStatus", Log: fmt.Sprintf("could not map scan doc to struct: %s", err)}
+	}
+	if s.State == "" {
+		s.State = CommandPending
+	}
+	return syncUserId, s.JobName, s.State, nil
+}
@@ -0,0 +1,146 @@
+package queue_connect
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"sort"
+	"speechLiason/errors"
+	"time"
+)
+
+type ScheduledDelivery struct {
+	JobName   string
+	Methods   []string
+	Scheduled time.Time
+	refs      []*firestore.DocumentRef
+}
+
+// ScheduleDelivery resolves and writes the deliveries now, but holds them until at
+func ScheduleDelivery(jobName, voiceUserId, possibleSyncUserId string, targets []DeliveryTarget, apiToken string, at time.Time) (syncUserId, sessionJobName string, err error) {
+	if !at.After(time.Now()) {
+		return possibleSyncUserId, jobName, errors.DeliveryTimePassedError{JobName: jobName, UserId: voiceUserId, Context: "ScheduleDelivery", Log: fmt.Sprintf("delivery time %s has passed", at)}
+	}
+	return queueDeliveries(jobName, voiceUserId, possibleSyncUserId, targets, apiToken, at)
+}
+
+// ListScheduledDeliveries returns the user's scheduled deliveries, soonest first
+func ListScheduledDeliveries(voiceUserId, possibleSyncUserId string) (syncUserId string, scheduled []ScheduledDelivery, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	scheduled, err = scheduledDeliveries(syncUserId)
+	return
+}
+
+// CancelScheduledDelivery cancels the soonest scheduled delivery, or the soonest of the named job
+func CancelScheduledDelivery(jobName, voiceUserId, possibleSyncUserId string) (syncUserId string, cancelled ScheduledDelivery, err error) {
+	syncUserId, err = getUserId(voiceUserId, possibleSyncUserId)
+	if err != nil {
+		return
+	}
+	if jobName != "" {
+		if jobName, _, err = resolveJobName(jobName, voiceUserId, syncUserId); err != nil {
+			return
+		}
+	}
+	scheduled, err := scheduledDeliveries(syncUserId)
+	if err != nil {
+		return
+	}
+	found := false
+	for _, s := range scheduled {
+		if jobName == "" || s.JobName == jobName {
+			cancelled, found = s, true
+			break
+		}
+	}
+	if !found {
+		return syncUserId, cancelled, errors.NothingToCancelError{JobName: jobName, UserId: voiceUserId, Context: "CancelScheduledDelivery", Log: "no matching scheduled deliveries"}
+	}
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snaps, err := tx.GetAll(cancelled.refs)
+		if err != nil {
+			return err
+		}
+		for _, snap := range snaps {
+			// the scheduler may have released it since we looked
+			if s, _ := snap.DataAt("s"); s != CommandScheduled {
+				return errors.NothingToCancelError{JobName: cancelled.JobName, UserId: voiceUserId, Context: "CancelScheduledDelivery", Log: fmt.Sprintf("delivery %s is no longer scheduled", snap.Ref.ID)}
+			}
+		}
+		for _, ref := range cancelled.refs {
+			if err = tx.Update(ref, []firestore.Update{{Path: "s", Value: CommandCancelled}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(errors.NothingToCancelError); ok {
+			return syncUserId, cancelled, err
+		}
+		return syncUserId, cancelled, errors.SystemError{JobName: cancelled.JobName, UserId: syncUserId, Context: "CancelScheduledDelivery", Log: fmt.Sprintf("could not cancel scheduled delivery: %s", err)}
+	}
+	return
+}
+
+// ReleaseDueDeliveries moves every scheduled delivery due by now into the queue; a delivery
+// changed since it was read, like one just cancelled, is left alone
+func ReleaseDueDeliveries(now time.Time) (released int, err error) {
+	docs, err := client.Collection("delivery").
+		Where("s", "==", CommandScheduled).
+		Where("w", "<=", now).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return 0, errors.SystemError{Context: "ReleaseDueDeliveries", Log: fmt.Sprintf("could not retrieve due deliveries: %s", err)}
+	}
+	for _, doc := range docs {
+		_, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "s", Value: CommandPending}, {Path: "t", Value: now}}, firestore.LastUpdateTime(doc.UpdateTime))
+		if err != nil {
+			fmt.Println(errors.SystemError{Context: "ReleaseDueDeliveries", Log: fmt.Sprintf("could not release delivery %s: %s", doc.Ref.ID, err)})
+			continue
+		}
+		released++
+	}
+	return
+}
+
+func scheduledDeliveries(syncUserId string) ([]ScheduledDelivery, error) {
+	docs, err := client.Collection("delivery").
+		Where("u", "==", syncUserId).
+		Where("s", "==", CommandScheduled).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.SystemError{UserId: syncUserId, Context: "scheduledDeliveries", Log: fmt.Sprintf("could not retrieve scheduled deliveries: %s", err)}
+	}
+	groups := map[string]*ScheduledDelivery{}
+	var scheduled []*ScheduledDelivery
+	for _, doc := range docs {
+		var d deliverDoc
+		if err = doc.DataTo(&d); err != nil {
+			return nil, errors.SystemError{UserId: syncUserId, Context: "scheduledDeliveries", Log: fmt.Sprintf("could not map delivery doc to struct: %s", err)}
+		}
+		key := d.Group
+		if key == "" {
+			key = doc.Ref.ID
+		}
+		s, ok := groups[key]
+		if !ok {
+			s = &ScheduledDelivery{JobName: d.JobName, Scheduled: d.Scheduled}
+			groups[key] = s
+			scheduled = append(scheduled, s)
+		}
+		s.Methods = append(s.Methods, d.Method)
+		s.refs = append(s.refs, doc.Ref)
+	}
+	sort.Slice(scheduled, func(i, j int) bool {
+		return scheduled[i].Scheduled.Before(scheduled[j].Scheduled)
+	})
+	result := make([]ScheduledDelivery, len(scheduled))
+	for i, s := range scheduled {
+		result[i] = *s
+	}
+	return result, nil
+}
@@ -1,6 +1,9 @@
 package respond
 
-import "github.com/arienmalec/alexa-go"
+import (
+	"github.com/arienmalec/alexa-go"
+	"strings"
+)
 
 func Welcome() alexa.Response {
 	return createResponse("Welcome!", false, "", "")
@@ -24,6 +27,96 @@ func Openly(message string, endSession bool, syncUserId string, jobName string)
 	return createResponse(message, endSession, syncUserId, jobName)
 }
 
+func Questioningly(question string, pendingAction string, syncUserId string, jobName string) alexa.Response {
+	r := createResponse(question, false, syncUserId, jobName)
+	r.SessionAttributes["pendingConfirmation"] = pendingAction
+	return r
+}
+
+func Visibly(message string, cardTitle string, cardText string, endSession bool, syncUserId string, jobName string) alexa.Response {
+	r := createResponse(message, endSession, syncUserId, jobName)
+	r.Body.Card = &alexa.Payload{Type: "Simple", Title: cardTitle, Content: cardText}
+	return r
+}
+
+func LinkAccount(message string, endSession bool) alexa.Response {
+	r := createResponse(message, endSession, "", "")
+	r.Body.Card = &alexa.Payload{Type: "LinkAccount"}
+	return r
+}
+
+func Enumerate(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	default:
+		return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+	}
+}
+
+// Response wraps alexa.Response for directives alexa-go has no type for
+type Response struct {
+	alexa.Response
+	Body ResBody `json:"response"`
+}
+
+type ResBody struct {
+	alexa.ResBody
+	Directives []interface{} `json:"directives,omitempty"`
+}
+
+type DynamicEntity struct {
+	Id       string
+	Value    string
+	Synonyms []string
+}
+
+type entityValue struct {
+	Id   string `json:"id"`
+	Name struct {
+		Value    string   `json:"value"`
+		Synonyms []string `json:"synonyms,omitempty"`
+	} `json:"name"`
+}
+
+type entityType struct {
+	Name   string        `json:"name"`
+	Values []entityValue `json:"values"`
+}
+
+type updateDynamicEntities struct {
+	Type           string       `json:"type"`
+	UpdateBehavior string       `json:"updateBehavior"`
+	Types          []entityType `json:"types"`
+}
+
+func Wrap(r alexa.Response) Response {
+	w := Response{Response: r, Body: ResBody{ResBody: r.Body}}
+	for _, d := range r.Body.Directives {
+		w.Body.Directives = append(w.Body.Directives, d)
+	}
+	return w
+}
+
+func WithDynamicEntities(r alexa.Response, slotType string, entities []DynamicEntity) Response {
+	w := Wrap(r)
+	// entities only apply for the rest of the session, so there's no point sending them as it ends
+	if r.Body.ShouldEndSession || len(entities) == 0 {
+		return w
+	}
+	t := entityType{Name: slotType}
+	for _, e := range entities {
+		v := entityValue{Id: e.Id}
+		v.Name.Value = e.Value
+		v.Name.Synonyms = e.Synonyms
+		t.Values = append(t.Values, v)
+	}
+	w.Body.Directives = append(w.Body.Directives, updateDynamicEntities{Type: "Dialog.UpdateDynamicEntities", UpdateBehavior: "REPLACE", Types: []entityType{t}})
+	return w
+}
+
 func createResponse(outputText string, endSession bool, syncUserId string, jobName string) alexa.Response {
 	var r alexa.Response
 	var p alexa.Payload
@@ -0,0 +1,275 @@
+package voice_request
+
+import (
+	"fmt"
+	"github.com/arienmalec/alexa-go"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const personSeparator = "#"
+const dynamicAuthority = ".er-authority.echo-sdk.dynamic."
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+var isoWeek = regexp.MustCompile(`^(\d{4})-W(\d{2})(-WE)?$`)
+
+// AMAZON.TIME values for a part of the day, and the hour they're taken to mean
+var timesOfDay = map[string]int{"MO": 9, "AF": 14, "EV": 18, "NI": 21}
+
+const defaultDeliveryHour = 9
+
+// deliveries asked for today with no time go out at the next quarter hour
+const deliverySlot = 15 * time.Minute
+
+// entity resolution status codes
+const (
+	ResolutionMatch   = "ER_SUCCESS_MATCH"
+	ResolutionNoMatch = "ER_SUCCESS_NO_MATCH"
+)
+
+// confirmation status values, for intents and for slots
+const (
+	ConfirmationNone      = "NONE"
+	ConfirmationConfirmed = "CONFIRMED"
+	ConfirmationDenied    = "DENIED"
+)
+
+// Request extends alexa.Request with the parts of the request envelope alexa-go doesn't decode
+type Request struct {
+	alexa.Request
+	Body    ReqBody `json:"request"`
+	Context Context `json:"context"`
+}
+
+type ReqBody struct {
+	Type        string `json:"type"`
+	RequestID   string `json:"requestId"`
+	Timestamp   string `json:"timestamp"`
+	Locale      string `json:"locale"`
+	Intent      Intent `json:"intent,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	DialogState string `json:"dialogState,omitempty"`
+}
+
+type Intent struct {
+	Name               string          `json:"name"`
+	ConfirmationStatus string          `json:"confirmationStatus,omitempty"`
+	Slots              map[string]Slot `json:"slots"`
+}
+
+type Slot struct {
+	Name               string      `json:"name"`
+	Value              string      `json:"value"`
+	ConfirmationStatus string      `json:"confirmationStatus,omitempty"`
+	Resolutions        Resolutions `json:"resolutions"`
+}
+
+type Resolutions struct {
+	ResolutionsPerAuthority []Resolution `json:"resolutionsPerAuthority"`
+}
+
+type Resolution struct {
+	Authority string `json:"authority"`
+	Status    struct {
+		Code string `json:"code"`
+	} `json:"status"`
+	Values []struct {
+		Value struct {
+			Name string `json:"name"`
+			Id   string `json:"id"`
+		} `json:"value"`
+	} `json:"values"`
+}
+
+type Context struct {
+	System struct {
+		APIAccessToken string `json:"apiAccessToken"`
+		Device         struct {
+			DeviceID string `json:"deviceId,omitempty"`
+		} `json:"device,omitempty"`
+		Application struct {
+			ApplicationID string `json:"applicationId,omitempty"`
+		} `json:"application,omitempty"`
+		Person struct {
+			PersonID    string `json:"personId,omitempty"`
+			AccessToken string `json:"accessToken,omitempty"`
+		} `json:"person,omitempty"`
+	} `json:"System,omitempty"`
+}
+
+// SpeakerId identifies the recognized person when there is one, and the Amazon account otherwise
+func (r Request) SpeakerId() string {
+	if p := r.Context.System.Person.PersonID; p != "" {
+		return r.Session.User.UserID + personSeparator + p
+	}
+	return r.Session.User.UserID
+}
+
+// SlotResult summarizes what Alexa heard for a slot and what entity resolution made of it
+type SlotResult struct {
+	Spoken             string
+	ResolvedId         string
+	ResolvedName       string
+	Status             string
+	ConfirmationStatus string
+}
+
+// Slot prefers a dynamic entity match, then a static one; Status is empty when no resolution ran
+func (r Request) Slot(name string) SlotResult {
+	slot := r.Body.Intent.Slots[name]
+	result := SlotResult{Spoken: slot.Value, ConfirmationStatus: slot.ConfirmationStatus}
+	for _, a := range slot.Resolutions.ResolutionsPerAuthority {
+		dynamic := strings.Contains(a.Authority, dynamicAuthority)
+		if a.Status.Code != ResolutionMatch || len(a.Values) == 0 {
+			if result.Status == "" {
+				result.Status = a.Status.Code
+			}
+			continue
+		}
+		if result.Status != ResolutionMatch || dynamic {
+			result.Status = ResolutionMatch
+			result.ResolvedId = a.Values[0].Value.Id
+			result.ResolvedName = a.Values[0].Value.Name
+		}
+	}
+	return result
+}
+
+func (s SlotResult) Value() string {
+	if s.Status == ResolutionMatch && s.ResolvedId != "" {
+		return s.ResolvedId
+	}
+	return s.Spoken
+}
+
+func (r Request) SlotValues() map[string]string {
+	values := make(map[string]string, len(r.Body.Intent.Slots))
+	for name := range r.Body.Intent.Slots {
+		values[name] = r.Slot(name).Value()
+	}
+	return values
+}
+
+// Unrecognized means Alexa didn't catch a value, or resolution ran and nothing matched
+func (s SlotResult) Unrecognized() bool {
+	return s.Spoken == "" || s.Spoken == "?" || s.Status == ResolutionNoMatch
+}
+
+func (r Request) SessionString(key string) string {
+	if v, ok := r.Session.Attributes[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// SessionInt reads a numeric session attribute, which comes back from JSON as a float64
+func (r Request) SessionInt(key string) int {
+	if v, ok := r.Session.Attributes[key].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
+
+// ParseDuration reads the ISO 8601 durations AMAZON.DURATION slots produce, like PT30M or P1DT2H
+func ParseDuration(value string) (time.Duration, error) {
+	m := isoDuration.FindStringSubmatch(value)
+	if m == nil || value == "P" || value == "PT" {
+		return 0, fmt.Errorf("unsupported duration %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
+
+// PastTimeError is returned when every day the user's date covers has gone by
+type PastTimeError struct {
+	At time.Time
+}
+
+func (e PastTimeError) Error() string {
+	return fmt.Sprintf("%s has already passed", e.At)
+}
+
+// ParseDateTime reads AMAZON.DATE and AMAZON.TIME slot values as a moment in loc, which should
+// be the device's time zone. A time that has passed moves to the next day the date covers, so a
+// missing date, or PRESENT_REF, means today or tomorrow, and a week means any day of it; a named
+// day that has passed is a PastTimeError. A missing time means the start of the working day, or
+// the next slot if that's passed on a day named as today.
+func ParseDateTime(date, timeOfDay string, loc *time.Location, now time.Time) (time.Time, error) {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	day, days := today, 2
+	if date != "" && date != "PRESENT_REF" {
+		var err error
+		if day, days, err = parseDate(date, loc, today); err != nil {
+			return time.Time{}, err
+		}
+	}
+	hour, minute := defaultDeliveryHour, 0
+	if timeOfDay != "" {
+		if h, ok := timesOfDay[timeOfDay]; ok {
+			hour = h
+		} else if t, err := time.Parse("15:04", timeOfDay); err == nil {
+			hour, minute = t.Hour(), t.Minute()
+		} else {
+			return time.Time{}, fmt.Errorf("unsupported time %q", timeOfDay)
+		}
+	}
+	at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+	if timeOfDay == "" && days == 1 && day.Equal(today) && !at.After(now) {
+		at = now.Truncate(deliverySlot).Add(deliverySlot)
+	}
+	for d := 1; !at.After(now) && d < days; d++ {
+		at = at.AddDate(0, 0, 1)
+	}
+	if !at.After(now) {
+		return time.Time{}, PastTimeError{At: at}
+	}
+	return at, nil
+}
+
+// parseDate returns the first day date covers, and how many days it covers
+func parseDate(date string, loc *time.Location, today time.Time) (time.Time, int, error) {
+	// a date spoken without a year, like "october twentieth", is the next one to come
+	if strings.HasPrefix(date, "XXXX-") {
+		d, err := time.ParseInLocation("2006-01-02", strconv.Itoa(today.Year())+date[4:], loc)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("unsupported date %q", date)
+		}
+		if d.Before(today) {
+			d = d.AddDate(1, 0, 0)
+		}
+		return d, 1, nil
+	}
+	if d, err := time.ParseInLocation("2006-01-02", date, loc); err == nil {
+		return d, 1, nil
+	}
+	// a week starts on its Monday, and its weekend on Saturday
+	if m := isoWeek.FindStringSubmatch(date); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		week, _ := strconv.Atoi(m[2])
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+		monday := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(week-1)*7)
+		if m[3] != "" {
+			return monday.AddDate(0, 0, 5), 2, nil
+		}
+		return monday, 7, nil
+	}
+	return time.Time{}, 0, fmt.Errorf("unsupported date %q", date)
+}
+
+func AccountUserId(speakerId string) string {
+	return strings.SplitN(speakerId, personSeparator, 2)[0]
+}
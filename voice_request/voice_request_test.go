@@ -0,0 +1,86 @@
+package voice_request
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateTime(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, ny)
+	}
+	// a Monday morning, after the default delivery hour
+	now := at(2026, 10, 19, 10, 30)
+	cases := []struct {
+		date, timeOfDay string
+		want            time.Time
+		passed          bool
+	}{
+		{"", "15:00", at(2026, 10, 19, 15, 0), false},
+		{"", "08:00", at(2026, 10, 20, 8, 0), false},
+		{"", "EV", at(2026, 10, 19, 18, 0), false},
+		{"PRESENT_REF", "NI", at(2026, 10, 19, 21, 0), false},
+		{"PRESENT_REF", "09:00", at(2026, 10, 20, 9, 0), false},
+		{"2026-10-19", "15:30", at(2026, 10, 19, 15, 30), false},
+		{"2026-10-19", "", at(2026, 10, 19, 10, 45), false},
+		{"2026-10-18", "AF", time.Time{}, true},
+		{"2026-10-21", "", at(2026, 10, 21, 9, 0), false},
+		{"XXXX-10-25", "MO", at(2026, 10, 25, 9, 0), false},
+		{"XXXX-10-12", "", at(2027, 10, 12, 9, 0), false},
+		{"2026-W43", "", at(2026, 10, 20, 9, 0), false},
+		{"2026-W43", "14:00", at(2026, 10, 19, 14, 0), false},
+		{"2026-W43-WE", "", at(2026, 10, 24, 9, 0), false},
+		{"2026-W44", "", at(2026, 10, 26, 9, 0), false},
+		{"2026-W42", "", time.Time{}, true},
+	}
+	for _, c := range cases {
+		got, err := ParseDateTime(c.date, c.timeOfDay, ny, now)
+		if _, passed := err.(PastTimeError); passed != c.passed {
+			t.Errorf("%q %q: got error %v, want passed %v", c.date, c.timeOfDay, err, c.passed)
+			continue
+		}
+		if err == nil && !got.Equal(c.want) {
+			t.Errorf("%q %q: got %s, want %s", c.date, c.timeOfDay, got, c.want)
+		}
+	}
+}
+
+func TestParseDateTimeTodayWithoutTime(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		now, want time.Time
+	}{
+		// before the default hour, today still means the start of the working day
+		{time.Date(2026, 10, 19, 7, 10, 0, 0, ny), time.Date(2026, 10, 19, 9, 0, 0, 0, ny)},
+		{time.Date(2026, 10, 19, 9, 0, 0, 0, ny), time.Date(2026, 10, 19, 9, 15, 0, 0, ny)},
+		{time.Date(2026, 10, 19, 13, 44, 59, 0, ny), time.Date(2026, 10, 19, 13, 45, 0, 0, ny)},
+		{time.Date(2026, 10, 19, 13, 45, 0, 0, ny), time.Date(2026, 10, 19, 14, 0, 0, 0, ny)},
+		{time.Date(2026, 10, 19, 23, 50, 0, 0, ny), time.Date(2026, 10, 20, 0, 0, 0, 0, ny)},
+	}
+	for _, c := range cases {
+		got, err := ParseDateTime("2026-10-19", "", ny, c.now)
+		if err != nil {
+			t.Errorf("at %s: unexpected error: %s", c.now, err)
+			continue
+		}
+		if !got.Equal(c.want) {
+			t.Errorf("at %s: got %s, want %s", c.now, got, c.want)
+		}
+	}
+}
+
+func TestParseDateTimeRejectsUnsupportedValues(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC)
+	for _, c := range [][2]string{{"2026", ""}, {"2026-10", ""}, {"", "tea time"}, {"XXXX-02-30", ""}} {
+		if _, err := ParseDateTime(c[0], c[1], time.UTC, now); err == nil {
+			t.Errorf("%q %q: expected an error", c[0], c[1])
+		}
+	}
+}